@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/api/reply"
+	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/logger"
+)
+
+// GetLogLevel 查询当前的日志级别
+func GetLogLevel(c *gin.Context) (interface{}, error) {
+	return &reply.LogLevelReply{Level: logger.GetLevel()}, nil
+}
+
+// SetLogLevel 运行时调整日志级别
+func SetLogLevel(c *gin.Context) (interface{}, error) {
+	request := new(request.LogLevelUpdate)
+	if err := c.ShouldBindJSON(request); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	oldLevel := logger.GetLevel()
+	if err := logger.SetLevel(request.Level); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	logger.Warn(c, "LogLevelChanged", "from", oldLevel, "to", request.Level)
+	return &reply.LogLevelReply{Level: logger.GetLevel()}, nil
+}
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -62,70 +61,50 @@ func TestResponseList(c *gin.Context) {
 	return
 }
 
-func TestGormLogger(c *gin.Context) {
+func TestGormLogger(c *gin.Context) (interface{}, error) {
 	svc := appservice.NewDemoAppSvc(c)
-	list, err := svc.GetDemoIdentities()
-	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		return
-	}
-	app.NewResponse(c).Success(list)
-	return
+	return svc.GetDemoIdentities()
 }
 
-func TestCreateDemoOrder(c *gin.Context) {
+func TestCreateDemoOrder(c *gin.Context) (interface{}, error) {
 	request := new(request.DemoOrderCreate)
-	err := c.ShouldBind(request)
-	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+	if err := c.ShouldBind(request); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
 	}
 	// 验证用户信息 Token 然后把UserID赋值上去 这里测试就直接赋值了
 	request.UserId = 123453453
 	svc := appservice.NewDemoAppSvc(c)
-	reply, err := svc.CreateDemoOrder(request)
-	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		return
-	}
-	app.NewResponse(c).Success(reply)
+	return svc.CreateDemoOrder(request)
 }
 
-func TestForHttpToolGet(c *gin.Context) {
-	ipDetail, err := library.NewWhoisLib(c).GetHostIpDetail()
+// TestListDemoOrders 测试列表的过滤、排序和分页
+// ?filter[state][in]=1,2&sort=-bill_money&cursor=&page_size=10
+func TestListDemoOrders(c *gin.Context) (interface{}, error) {
+	spec, err := app.NewQuerySpec(c, request.DemoOrderListQuery)
 	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		return
+		return nil, err
 	}
-
-	app.NewResponse(c).Success(ipDetail)
-}
-
-func TestForHttpToolPost(c *gin.Context) {
-
-	orderReply, err := library.NewDemoLib(c).TestPostCreateOrder()
+	pagination := app.NewPaginaton(c)
+	svc := appservice.NewDemoAppSvc(c)
+	list, err := svc.ListDemoOrders(spec, pagination)
 	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		return
+		return nil, err
 	}
+	app.NewResponse(c).SetPagination(pagination).Success(list)
+	return nil, nil
+}
+
+func TestForHttpToolGet(c *gin.Context) (interface{}, error) {
+	return library.NewWhoisLib(c).GetHostIpDetail()
+}
 
-	app.NewResponse(c).Success(orderReply)
+func TestForHttpToolPost(c *gin.Context) (interface{}, error) {
+	return library.NewDemoLib(c).TestPostCreateOrder()
 }
 
-func TestMakeToken(c *gin.Context) {
+func TestMakeToken(c *gin.Context) (interface{}, error) {
 	userSvc := appservice.NewUserAppSvc(c)
-	token, err := userSvc.GenToken()
-	if err != nil {
-		if errors.Is(err, errcode.ErrUserInvalid) {
-			logger.Error(c, "invalid user is unable to generate token", err)
-			app.NewResponse(c).Error(errcode.ErrUserInvalid)
-		} else {
-			appErr := err.(*errcode.AppError)
-			app.NewResponse(c).Error(appErr)
-		}
-		return
-	}
-	app.NewResponse(c).Success(token)
+	return userSvc.GenToken()
 }
 
 func TestAuthToken(c *gin.Context) {
@@ -136,24 +115,12 @@ func TestAuthToken(c *gin.Context) {
 	return
 }
 
-func TestRefreshToken(c *gin.Context) {
+func TestRefreshToken(c *gin.Context) (interface{}, error) {
 	refreshToken := c.Query("refresh_token")
 	if refreshToken == "" {
-		app.NewResponse(c).Error(errcode.ErrParams)
-		return
+		return nil, errcode.ErrParams
 	}
+	// 客户端有并发刷新token时返回 ErrTooManyRequests
 	userSvc := appservice.NewUserAppSvc(c)
-	token, err := userSvc.TokenRefresh(refreshToken)
-	if err != nil {
-		if errors.Is(err, errcode.ErrTooManyRequests) {
-			// 客户端有并发刷新token
-			app.NewResponse(c).Error(errcode.ErrTooManyRequests)
-			return
-		} else {
-			appErr := err.(*errcode.AppError)
-			app.NewResponse(c).Error(appErr)
-		}
-		return
-	}
-	app.NewResponse(c).Success(token)
+	return userSvc.TokenRefresh(refreshToken)
 }
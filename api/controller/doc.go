@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/i18n"
+)
+
+// ErrorCodeCatalog 错误码目录, 默认返回JSON, format=markdown 时返回Markdown文档
+func ErrorCodeCatalog(c *gin.Context) (interface{}, error) {
+	catalogRequest := new(request.ErrorCodeCatalog)
+	if err := c.ShouldBindQuery(catalogRequest); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	if catalogRequest.Format != i18n.CatalogFormatMarkdown {
+		return i18n.GetErrorCodeCatalog(), nil
+	}
+	var buf bytes.Buffer
+	if err := i18n.WriteErrorCodeCatalog(&buf, i18n.CatalogFormatMarkdown); err != nil {
+		return nil, errcode.ErrServer.WithCause(err)
+	}
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", buf.Bytes())
+	return nil, nil
+}
@@ -4,177 +4,121 @@ import (
 	"errors"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-study-lab/go-mall/api/request"
-	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/errcode"
-	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/util"
 	"github.com/go-study-lab/go-mall/logic/appservice"
 )
 
-func RefreshUserToken(c *gin.Context) {
-	refreshToken := c.Query("refresh_token")
-	if refreshToken == "" {
-		app.NewResponse(c).Error(errcode.ErrParams)
-		return
+func RefreshUserToken(c *gin.Context) (interface{}, error) {
+	refreshRequest := new(request.TokenRefresh)
+	if err := c.ShouldBindQuery(refreshRequest); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
 	}
-	userSvc := appservice.NewUserAppSvc(c)
-	token, err := userSvc.TokenRefresh(refreshToken)
-	if err != nil {
-		if errors.Is(err, errcode.ErrTooManyRequests) {
-			// 客户端有并发刷新token
-			app.NewResponse(c).Error(errcode.ErrTooManyRequests)
-		} else {
-			appErr := err.(*errcode.AppError)
-			app.NewResponse(c).Error(appErr)
-		}
-		return
+	// 客户端并发刷新token时返回 ErrTooManyRequests
+	return appservice.NewUserAppSvc(c).TokenRefresh(refreshRequest.RefreshToken)
+}
+
+// RefreshUserTokenV2 v2版本的刷新Token, RefreshToken 放在 JSON 请求体中
+func RefreshUserTokenV2(c *gin.Context) (interface{}, error) {
+	refreshRequest := new(request.TokenRefresh)
+	if err := c.ShouldBindJSON(refreshRequest); err != nil {
+		return nil, errcode.ErrParams.WithCause(err)
 	}
-	app.NewResponse(c).Success(token)
+	return appservice.NewUserAppSvc(c).TokenRefresh(refreshRequest.RefreshToken)
 }
-func RegisterUser(c *gin.Context) {
+
+func RegisterUser(c *gin.Context) (interface{}, error) {
 	userRequest := new(request.UserRegister)
 	if err := c.ShouldBind(userRequest); err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+		return nil, errcode.ErrParams.WithCause(err)
 	}
 	if !util.PasswordComplexityVerify(userRequest.Password) {
 		// Validator验证通过后再应用 密码复杂度这样的特殊验证
-		logger.Warn(c, "RegisterUserError", "err", "密码复杂度不满足", "password", userRequest.Password)
-		app.NewResponse(c).Error(errcode.ErrParams)
-		return
+		return nil, errcode.ErrParams.WithFieldErrors(util.PasswordComplexityFieldError("password"))
 	}
-	// 注册用户
+	// 注册用户, 用户名被占用时返回 ErrUserNameOccupied
 	userSvc := appservice.NewUserAppSvc(c)
-	err := userSvc.UserRegister(userRequest)
-	if err != nil {
-		if errors.Is(err, errcode.ErrUserNameOccupied) {
-			app.NewResponse(c).Error(errcode.ErrUserNameOccupied)
-		} else {
-			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		}
-		return
-	}
-
-	app.NewResponse(c).SuccessOk()
-	return
+	return nil, userSvc.UserRegister(userRequest)
 }
 
-func LoginUser(c *gin.Context) {
+func LoginUser(c *gin.Context) (interface{}, error) {
 	loginRequest := new(request.UserLogin)
 	if err := c.ShouldBindJSON(&loginRequest.Body); err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+		return nil, errcode.ErrParams.WithCause(err)
 	}
 	if err := c.ShouldBindHeader(&loginRequest.Header); err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	// 开启请求签名时以 VerifySignature 验证后的平台为准, 未签名的请求不能登录APP平台
+	// 客户端配置的平台可能不在允许登录的平台中, 替换后重新校验
+	if platform := c.GetString("platform"); platform != "" {
+		loginRequest.Header.Platform = platform
+		if err := binding.Validator.ValidateStruct(&loginRequest.Header); err != nil {
+			return nil, errcode.ErrParams.WithCause(err)
+		}
 	}
 	// 登录用户
 	userSvc := appservice.NewUserAppSvc(c)
 	token, err := userSvc.UserLogin(loginRequest)
+	if errors.Is(err, errcode.ErrUserInvalid) {
+		// 不向客户端透露用户的状态
+		return nil, errcode.ErrUserNotRight.WithCause(err)
+	}
 	if err != nil {
-		if errors.Is(err, errcode.ErrUserNotRight) {
-			app.NewResponse(c).Error(errcode.ErrUserNotRight)
-		} else if errors.Is(err, errcode.ErrUserInvalid) {
-			app.NewResponse(c).Error(errcode.ErrUserNotRight)
-		} else {
-			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		}
-		logger.Error(c, "LoginError", "err", err)
-		return
+		return nil, err
 	}
-
-	app.NewResponse(c).Success(token)
-	return
+	return token, nil
 }
 
-func LogoutUser(c *gin.Context) {
+func LogoutUser(c *gin.Context) (interface{}, error) {
 	userId := c.GetInt64("userId")
 	platform := c.GetString("platform")
 	userSvc := appservice.NewUserAppSvc(c)
-	err := userSvc.UserLogout(userId, platform)
-	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		return
-	}
-	app.NewResponse(c).SuccessOk()
+	return nil, userSvc.UserLogout(userId, platform)
 }
 
 // PasswordResetApply 申请重置密码
-func PasswordResetApply(c *gin.Context) {
+func PasswordResetApply(c *gin.Context) (interface{}, error) {
 	request := new(request.PasswordResetApply)
 	if err := c.ShouldBindJSON(request); err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+		return nil, errcode.ErrParams.WithCause(err)
 	}
 	userSvc := appservice.NewUserAppSvc(c)
-	reply, err := userSvc.PasswordResetApply(request)
-	if err != nil {
-		if errors.Is(err, errcode.ErrUserNotRight) {
-			app.NewResponse(c).Error(errcode.ErrUserNotRight)
-		} else {
-			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		}
-		return
-	}
-
-	app.NewResponse(c).Success(reply)
+	return userSvc.PasswordResetApply(request)
 }
 
-func PasswordReset(c *gin.Context) {
+func PasswordReset(c *gin.Context) (interface{}, error) {
 	request := new(request.PasswordReset)
 	if err := c.ShouldBindJSON(request); err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+		return nil, errcode.ErrParams.WithCause(err)
 	}
 	if !util.PasswordComplexityVerify(request.Password) {
 		// Validator验证通过后再应用 密码复杂度这样的特殊验证
-		logger.Warn(c, "RegisterUserError", "err", "密码复杂度不满足", "password", request.Password)
-		app.NewResponse(c).Error(errcode.ErrParams)
-		return
+		return nil, errcode.ErrParams.WithFieldErrors(util.PasswordComplexityFieldError("password"))
 	}
 	userSvc := appservice.NewUserAppSvc(c)
-	err := userSvc.PasswordReset(request)
-	if err != nil {
-		if errors.Is(err, errcode.ErrParams) {
-			app.NewResponse(c).Error(errcode.ErrParams)
-		} else if errors.Is(err, errcode.ErrUserInvalid) {
-			app.NewResponse(c).Error(errcode.ErrUserInvalid)
-		} else {
-			app.NewResponse(c).Error(errcode.ErrServer)
-		}
-		return
-	}
-
-	app.NewResponse(c).SuccessOk()
+	return nil, userSvc.PasswordReset(request)
 }
 
 // UserInfo 个人信息查询
-func UserInfo(c *gin.Context) {
+func UserInfo(c *gin.Context) (interface{}, error) {
 	userId := c.GetInt64("userId")
 	userSvc := appservice.NewUserAppSvc(c)
 	userInfoReply := userSvc.UserInfo(userId)
 	if userInfoReply == nil {
-		app.NewResponse(c).Error(errcode.ErrParams)
-		return
+		return nil, errcode.ErrParams
 	}
-	app.NewResponse(c).Success(userInfoReply)
+	return userInfoReply, nil
 }
 
 // UpdateUserInfo 个人信息更新
-func UpdateUserInfo(c *gin.Context) {
+func UpdateUserInfo(c *gin.Context) (interface{}, error) {
 	request := new(request.UserInfoUpdate)
 	if err := c.ShouldBindJSON(request); err != nil {
-		app.NewResponse(c).Error(errcode.ErrParams.WithCause(err))
-		return
+		return nil, errcode.ErrParams.WithCause(err)
 	}
 	userSvc := appservice.NewUserAppSvc(c)
-	err := userSvc.UserInfoUpdate(request, c.GetInt64("userId"))
-	if err != nil {
-		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
-		return
-	}
-
-	app.NewResponse(c).SuccessOk()
+	return nil, userSvc.UserInfoUpdate(request, c.GetInt64("userId"))
 }
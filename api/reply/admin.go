@@ -0,0 +1,5 @@
+package reply
+
+type LogLevelReply struct {
+	Level string `json:"level"`
+}
@@ -0,0 +1,5 @@
+package request
+
+type LogLevelUpdate struct {
+	Level string `json:"level" binding:"required,oneof=debug info warn error"`
+}
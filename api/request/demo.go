@@ -1,7 +1,20 @@
 package request
 
+import "github.com/go-study-lab/go-mall/common/app"
+
 type DemoOrderCreate struct {
 	UserId       int64 `json:"user_id"`
 	BillMoney    int64 `json:"bill_money" binding:"required"`
 	OrderGoodsId int64 `json:"order_goods_id" binding:"required"`
 }
+
+// DemoOrderListQuery 订单列表允许的过滤和排序
+var DemoOrderListQuery = &app.QueryRule{
+	Fields: []app.QueryField{
+		{Name: "state", Type: app.QueryInt, Ops: []string{app.OpEq, app.OpNe, app.OpIn}},
+		{Name: "bill_money", Type: app.QueryInt, Ops: []string{app.OpGte, app.OpLte}, Sortable: true},
+		{Name: "order_no", Ops: []string{app.OpEq}},
+		{Name: "created_at", Type: app.QueryTime, Ops: []string{app.OpGte, app.OpLt}, Sortable: true},
+	},
+	DefaultSort: "-created_at",
+}
@@ -0,0 +1,5 @@
+package request
+
+type ErrorCodeCatalog struct {
+	Format string `form:"format" binding:"omitempty,oneof=json markdown"` // 默认返回JSON
+}
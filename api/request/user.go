@@ -37,3 +37,8 @@ type PasswordReset struct {
 	Token           string `json:"password_reset_token" binding:"required"`
 	Code            string `json:"password_reset_code" binding:"required"`
 }
+
+// TokenRefresh v1 通过查询参数传递, v2 改为 JSON 请求体, 避免 RefreshToken 出现在访问日志中
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/api/controller"
+	"github.com/go-study-lab/go-mall/api/reply"
+	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/app"
+	"github.com/go-study-lab/go-mall/common/middleware"
+	"github.com/go-study-lab/go-mall/common/openapi"
+)
+
+// 存放运维管理相关的路由
+func registerAdminRoutes(rg *gin.RouterGroup) {
+	// 这个路由组中的路由都以 /admin 开头, 需要通过管理Token认证
+	g := openapi.NewGroup(rg.Group("/admin/", middleware.AuthAdmin()), "运维管理")
+	// 查询当前日志级别
+	g.GET("log/level", openapi.Operation{
+		Summary:  "查询当前日志级别",
+		Security: []string{securityAdminToken},
+		Reply:    reply.LogLevelReply{},
+	}, app.Handle(controller.GetLogLevel))
+	// 动态调整日志级别
+	g.PUT("log/level", openapi.Operation{
+		Summary:  "调整日志级别",
+		Security: []string{securityAdminToken},
+		Body:     request.LogLevelUpdate{},
+		Reply:    reply.LogLevelReply{},
+	}, app.Handle(controller.SetLogLevel))
+}
@@ -3,29 +3,34 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-study-lab/go-mall/api/controller"
+	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/middleware"
+	"github.com/go-study-lab/go-mall/common/openapi"
 )
 
 func registerBuildingRoutes(rg *gin.RouterGroup) {
-	// 这个路由组中的路由都以/building 开头
-	g := rg.Group("/building/")
+	// 这个路由组中的路由都以/building 开头, 都是测试用的接口, 不出现在接口文档中
+	g := openapi.NewGroup(rg.Group("/building/"), "测试").Hide()
 	// 测试ping
-	g.GET("/ping", controller.TestPing)
+	g.GET("/ping", openapi.Operation{}, controller.TestPing)
 	// 测试日志文件的读取
-	g.GET("config-read", controller.TestConfigRead)
+	g.GET("config-read", openapi.Operation{}, controller.TestConfigRead)
 	// 测试日志门面Logger的使用
-	g.GET("logger-test", controller.TestLogger)
+	g.GET("logger-test", openapi.Operation{}, controller.TestLogger)
 	// 测试服务的访问日志
-	g.POST("access-log-test", controller.TestAccessLog)
+	g.POST("access-log-test", openapi.Operation{}, controller.TestAccessLog)
 	// 测试统一响应--返回列表和分页
-	g.GET("response-list", controller.TestResponseList)
+	g.GET("response-list", openapi.Operation{}, controller.TestResponseList)
 	// 测试gorm的日志
-	g.GET("gorm-logger-test", controller.TestGormLogger)
-	g.POST("create-demo-order", controller.TestCreateDemoOrder)
+	g.GET("gorm-logger-test", openapi.Operation{}, app.Handle(controller.TestGormLogger))
+	// 测试创建订单, 携带 Idempotency-Key 重试时不会重复创建
+	g.POST("create-demo-order", openapi.Operation{Idempotent: true}, middleware.Idempotent(), app.Handle(controller.TestCreateDemoOrder))
+	// 测试列表的过滤、排序和分页
+	g.GET("demo-order-list", openapi.Operation{}, app.Handle(controller.TestListDemoOrders))
 	// 测试封装的httptool
-	g.GET("httptool-get-test", controller.TestForHttpToolGet)
-	g.GET("token-make-test", controller.TestMakeToken)
-	g.GET("token-auth-test", middleware.AuthUser(), controller.TestAuthToken)
+	g.GET("httptool-get-test", openapi.Operation{}, app.Handle(controller.TestForHttpToolGet))
+	g.GET("token-make-test", openapi.Operation{}, app.Handle(controller.TestMakeToken))
+	g.GET("token-auth-test", openapi.Operation{}, middleware.AuthUser(), controller.TestAuthToken)
 	// 测试刷新Token
-	g.GET("token-refresh-test", controller.TestRefreshToken)
+	g.GET("token-refresh-test", openapi.Operation{}, app.Handle(controller.TestRefreshToken))
 }
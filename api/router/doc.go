@@ -0,0 +1,49 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/api/controller"
+	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/app"
+	"github.com/go-study-lab/go-mall/common/enum"
+	"github.com/go-study-lab/go-mall/common/i18n"
+	"github.com/go-study-lab/go-mall/common/openapi"
+	"github.com/go-study-lab/go-mall/config"
+)
+
+// 接口文档中的认证方式
+const (
+	securityUserToken  = "user-token"
+	securityAdminToken = "admin-token"
+)
+
+var apiInfo = openapi.Info{
+	Title:       "go-mall API",
+	Description: "所有接口的响应都包在 code/msg/data 结构中, code 为 0 表示成功; 用户接口按版本提供, 路径中带上版本号(/v2/user/...)或者用请求头 api-version 指定版本",
+	Version:     "1.0.0",
+}
+
+func init() {
+	openapi.RegisterSecurityScheme(securityUserToken, "user-token", "登录后获取的 access_token")
+	openapi.RegisterSecurityScheme(securityAdminToken, "admin-token", "配置 app.admin.token 中的管理Token")
+}
+
+// 存放接口文档相关的路由, 提供给客户端团队
+func registerDocRoutes(rg *gin.RouterGroup) {
+	g := openapi.NewGroup(rg.Group("/doc/"), "接口文档")
+	// 错误码目录
+	g.GET("error-codes", openapi.Operation{
+		Summary: "错误码目录",
+		Query:   request.ErrorCodeCatalog{},
+		Reply:   i18n.ErrorCodeCatalog{},
+	}, app.Handle(controller.ErrorCodeCatalog))
+
+	spec := openapi.NewGroup(rg, "OpenAPI").Hide()
+	// OpenAPI 文档
+	spec.GET("openapi.json", openapi.Operation{}, openapi.SpecHandler(apiInfo))
+	if config.App().Env == enum.ModeDev {
+		// 开发环境提供 Swagger UI
+		spec.GET("swagger", openapi.Operation{}, openapi.SwaggerUIHandler(apiInfo.Title, "/openapi.json", "/swagger-ui/"))
+		spec.GET("swagger-ui/*file", openapi.Operation{}, openapi.SwaggerUIAssetsHandler())
+	}
+}
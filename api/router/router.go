@@ -1,14 +1,29 @@
 package router
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/middleware"
+	"github.com/go-study-lab/go-mall/common/openapi"
 )
 
+// apiVersions 按版本注册的接口支持的版本, 目前只有用户接口按版本注册
+var apiVersions = []string{"v1", "v2"}
+
 func RegisterRoutes(engine *gin.Engine) {
 	// use global middleware
-	engine.Use(middleware.StartTrace(), middleware.LogAccess(), middleware.GinPanicRecovery())
+	engine.Use(middleware.StartTrace(), middleware.Locale(), middleware.APIVersion(engine, apiVersions, "/user/"), middleware.ForceDebugLog(), middleware.LogAccess(), middleware.GinPanicRecovery(), middleware.RateLimit(), middleware.VerifySignature(), middleware.ReadYourWrites(), middleware.HandleErrors())
 	routeGroup := engine.Group("")
 	registerBuildingRoutes(routeGroup)
 	registerUserRoutes(routeGroup)
+	registerAdminRoutes(routeGroup)
+	registerDocRoutes(routeGroup)
+
+	// 路由都要通过 openapi.Group 注册, 保证接口文档与路由一致
+	// 不一致时由 router_test 在测试阶段拦截, 启动时只记录错误日志, 不影响服务启动
+	if err := openapi.CheckRoutes(engine.Routes()); err != nil {
+		logger.Error(context.Background(), "OPENAPI_ROUTES_DRIFT", "err", err)
+	}
 }
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/openapi"
+)
+
+// 设置环境变量 OPENAPI_UPDATE=1 运行测试时重新生成 golden 文件
+const openAPIUpdateEnv = "OPENAPI_UPDATE"
+
+var (
+	engineOnce sync.Once
+	engine     *gin.Engine
+)
+
+// testEngine 路由只能注册一次, 重复注册会重复登记接口文档
+func testEngine() *gin.Engine {
+	engineOnce.Do(func() {
+		gin.SetMode(gin.TestMode)
+		engine = gin.New()
+		RegisterRoutes(engine)
+	})
+	return engine
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	if err := openapi.CheckRoutes(testEngine().Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPIGolden(t *testing.T) {
+	testEngine()
+	got, err := json.MarshalIndent(openapi.Build(apiInfo), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", "openapi.json")
+	if os.Getenv(openAPIUpdateEnv) != "" {
+		if err = os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v, run with %s=1 to generate it", err, openAPIUpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("OpenAPI spec differs from %s, review the change and run with %s=1 to update it", path, openAPIUpdateEnv)
+	}
+}
@@ -1,29 +1,91 @@
 package router
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-study-lab/go-mall/api/controller"
+	"github.com/go-study-lab/go-mall/api/reply"
+	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/middleware"
+	"github.com/go-study-lab/go-mall/common/openapi"
 )
 
-// 存放User模块的路由
+// v1 的刷新Token接口通过查询参数传递 RefreshToken, 已经被 v2 替代
+var tokenRefreshV1 = openapi.Operation{
+	Summary:      "刷新Token",
+	Query:        request.TokenRefresh{},
+	Reply:        reply.TokenReply{},
+	Deprecated:   true,
+	DeprecatedAt: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+	Sunset:       time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC),
+	Successor:    "/v2/user/token/refresh",
+}
+
+// 存放User模块的路由, 用户接口按版本注册, 客户端可以在路径中带上版本号, 也可以用请求头 api-version 指定版本
+// 未带版本号的路由与 v1 相同, 保证老版本的APP继续可用, 文档中只展示带版本号的路由
 func registerUserRoutes(rg *gin.RouterGroup) {
-	// 这个路由组中的路由都以 /user 开头
-	g := rg.Group("/user/")
-	// 刷新Token
-	g.GET("token/refresh", controller.RefreshUserToken)
+	legacy := openapi.NewGroup(rg.Group("/user/"), "用户").Hide()
+	legacy.GET("token/refresh", tokenRefreshV1, app.Handle(controller.RefreshUserToken))
+	registerUserCommonRoutes(legacy)
+
+	v1 := openapi.NewGroup(rg.Group("/v1/user/"), "用户 v1")
+	v1.GET("token/refresh", tokenRefreshV1, app.Handle(controller.RefreshUserToken))
+	registerUserCommonRoutes(v1)
+
+	v2 := openapi.NewGroup(rg.Group("/v2/user/"), "用户 v2")
+	// 刷新Token, RefreshToken 改为放在请求体中
+	v2.POST("token/refresh", openapi.Operation{
+		Summary: "刷新Token",
+		Body:    request.TokenRefresh{},
+		Reply:   reply.TokenReply{},
+	}, app.Handle(controller.RefreshUserTokenV2))
+	registerUserCommonRoutes(v2)
+}
+
+// registerUserCommonRoutes 各个版本中没有变化的用户接口
+func registerUserCommonRoutes(g *openapi.Group) {
 	// 注册用户
-	g.POST("register", controller.RegisterUser)
+	g.POST("register", openapi.Operation{
+		Summary:     "注册用户",
+		Description: "登录名为手机号(E.164格式)或者邮箱, 密码至少8位并且包含大小写字母、数字和特殊字符",
+		Body:        request.UserRegister{},
+		Idempotent:  true,
+	}, middleware.Idempotent(), app.Handle(controller.RegisterUser))
 	// 登录
-	g.POST("login", controller.LoginUser)
+	g.POST("login", openapi.Operation{
+		Summary: "登录",
+		Header:  request.UserLogin{}.Header,
+		Body:    request.UserLogin{}.Body,
+		Reply:   reply.TokenReply{},
+	}, app.Handle(controller.LoginUser))
 	// 登出用户
-	g.DELETE("logout", middleware.AuthUser(), controller.LogoutUser)
+	g.DELETE("logout", openapi.Operation{
+		Summary:  "登出",
+		Security: []string{securityUserToken},
+	}, middleware.AuthUser(), app.Handle(controller.LogoutUser))
 	// 申请重置密码
-	g.POST("password/apply-reset", controller.PasswordResetApply)
+	g.POST("password/apply-reset", openapi.Operation{
+		Summary: "申请重置密码",
+		Body:    request.PasswordResetApply{},
+		Reply:   reply.PasswordResetApply{},
+	}, app.Handle(controller.PasswordResetApply))
 	// 重置密码
-	g.POST("password/reset", controller.PasswordReset)
+	g.POST("password/reset", openapi.Operation{
+		Summary: "重置密码",
+		Body:    request.PasswordReset{},
+	}, app.Handle(controller.PasswordReset))
 	// 用户基本信息
-	g.GET("info", middleware.AuthUser(), controller.UserInfo)
+	g.GET("info", openapi.Operation{
+		Summary:  "用户基本信息",
+		Security: []string{securityUserToken},
+		Reply:    reply.UserInfoReply{},
+	}, middleware.AuthUser(), app.Handle(controller.UserInfo))
 	// 更新用户基本信息
-	g.PATCH("info", middleware.AuthUser(), controller.UpdateUserInfo)
+	g.PATCH("info", openapi.Operation{
+		Summary:  "更新用户基本信息",
+		Security: []string{securityUserToken},
+		Body:     request.UserInfoUpdate{},
+	}, middleware.AuthUser(), app.Handle(controller.UpdateUserInfo))
 }
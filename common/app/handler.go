@@ -0,0 +1,32 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/errcode"
+)
+
+// HandlerFunc 返回响应数据和错误的控制器, 由 Handle 包装后注册到路由上
+//
+//	g.POST("login", app.Handle(controller.LoginUser))
+type HandlerFunc func(c *gin.Context) (interface{}, error)
+
+// Handle 把 HandlerFunc 包装成 gin.HandlerFunc
+// 控制器返回错误时交给 middleware.HandleErrors 统一转换成错误响应, 否则把返回的数据作为成功响应输出
+// 控制器自己输出了响应的(比如带分页的列表、下载文件), 返回 nil, nil 即可
+func Handle(h HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := h(c)
+		if appErr, ok := err.(*errcode.AppError); ok && appErr == nil {
+			// 返回值类型为 *errcode.AppError 的函数返回的 nil, 转成 error 后不等于 nil
+			err = nil
+		}
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		if !c.Writer.Written() {
+			NewResponse(c).Success(data)
+		}
+	}
+}
@@ -1,18 +1,43 @@
 package app
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/util"
 	"github.com/go-study-lab/go-mall/config"
 )
 
+// 支持两种分页方式, 由客户端通过查询参数选择, 查询时用 dao.FindPage 或 dao.PageScope 应用到SQL上
+// 偏移分页: page=2&page_size=20, 适合需要跳页的管理后台
+// 游标分页: cursor=&page_size=20, 第一页传空的 cursor, 之后传上一页响应中的 next_cursor,
+// next_cursor 为空表示没有下一页了; 翻页深度不影响查询性能, 适合无限滚动的列表
 type pagination struct {
-	Page      int `json:"page"`
-	PageSize  int `json:"page_size"`
-	TotalRows int `json:"total_rows"`
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	TotalRows  *int   `json:"total_rows,omitempty"` // 不统计总行数时不返回
+	NextCursor string `json:"next_cursor,omitempty"`
+	cursor     string
+	cursorMode bool
 }
 
+// PaginationQuery 分页的查询参数, 用于生成接口文档
+type PaginationQuery struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1"`
+	Cursor   string `form:"cursor"` // 游标分页时传上一页的 next_cursor, 第一页传空值
+}
+
+var errInvalidCursor = errcode.ErrParams.WithFieldErrors(errcode.FieldError{Field: "cursor", Rule: "cursor"})
+
 func NewPaginaton(c *gin.Context) *pagination {
 	page, _ := strconv.Atoi(c.Query("page"))
 	if page <= 0 {
@@ -20,9 +45,17 @@ func NewPaginaton(c *gin.Context) *pagination {
 	}
 	pageSize, _ := strconv.Atoi(c.Query("page_size"))
 	if pageSize <= 0 {
-		pageSize = config.App.Pagination.DefaultSize
+		pageSize = config.App().Pagination.DefaultSize
+	}
+	if pageSize > config.App().Pagination.MaxSize {
+		pageSize = config.App().Pagination.MaxSize
 	}
-	return &pagination{Page: page, PageSize: pageSize}
+	cursor, cursorMode := c.GetQuery("cursor")
+	if cursorMode {
+		// 游标分页没有页码
+		page = 0
+	}
+	return &pagination{Page: page, PageSize: pageSize, cursor: cursor, cursorMode: cursorMode}
 }
 
 func (p *pagination) GetPage() int {
@@ -33,9 +66,92 @@ func (p *pagination) GetPageSize() int {
 }
 
 func (p *pagination) SetTotalRows(total int) {
-	p.TotalRows = total
+	p.TotalRows = &total
 }
 
 func (p *pagination) Offset() int {
+	if p.cursorMode {
+		return 0
+	}
 	return (p.Page - 1) * p.PageSize
 }
+
+// IsCursorMode 是否为游标分页
+func (p *pagination) IsCursorMode() bool {
+	return p.cursorMode
+}
+
+// cursorPayload 游标中签名的内容, 带上生成游标时的排序, 换了排序后旧的游标不能再用
+type cursorPayload struct {
+	Sort   []string      `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// CursorValues 解析游标中上一页最后一行的排序键的值, 第一页返回 nil
+// sort 是排序键和排序方向, 如 ["created_at desc", "id asc"], 游标被篡改或者与排序不匹配时返回参数错误
+func (p *pagination) CursorValues(sort []string) ([]interface{}, error) {
+	if p.cursor == "" {
+		return nil, nil
+	}
+	data, sign, found := strings.Cut(p.cursor, ".")
+	if !found || !hmac.Equal([]byte(sign), []byte(util.HmacSHA256Hex(data, config.App().Pagination.CursorSecret))) {
+		return nil, errInvalidCursor
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var payload cursorPayload
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err = decoder.Decode(&payload); err != nil || !slices.Equal(payload.Sort, sort) || len(payload.Values) != len(sort) {
+		return nil, errInvalidCursor
+	}
+	values := payload.Values
+	for i, value := range values {
+		switch v := value.(type) {
+		case json.Number:
+			values[i] = cursorNumber(v)
+		case map[string]interface{}:
+			if values[i], err = cursorTime(v); err != nil {
+				return nil, errInvalidCursor
+			}
+		}
+	}
+	return values, nil
+}
+
+// SetNextCursor 用这一页最后一行的排序键的值生成下一页的游标, 游标签名防止客户端伪造
+func (p *pagination) SetNextCursor(sort []string, values []interface{}) error {
+	encoded := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if t, ok := value.(time.Time); ok {
+			// 时间按字符串传给数据库时, 不同数据库对格式的要求不同, 所以在游标中标记出来, 解析时还原成 time.Time
+			value = map[string]interface{}{cursorTimeKey: t.Format(time.RFC3339Nano)}
+		}
+		encoded = append(encoded, value)
+	}
+	raw, err := json.Marshal(cursorPayload{Sort: sort, Values: encoded})
+	if err != nil {
+		return err
+	}
+	data := base64.RawURLEncoding.EncodeToString(raw)
+	p.NextCursor = data + "." + util.HmacSHA256Hex(data, config.App().Pagination.CursorSecret)
+	return nil
+}
+
+const cursorTimeKey = "t"
+
+func cursorTime(v map[string]interface{}) (time.Time, error) {
+	value, _ := v[cursorTimeKey].(string)
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// cursorNumber 整数按 int64 解析, 避免大的ID转成 float64 后丢失精度
+func cursorNumber(number json.Number) interface{} {
+	if n, err := number.Int64(); err == nil {
+		return n
+	}
+	f, _ := number.Float64()
+	return f
+}
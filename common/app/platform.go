@@ -0,0 +1,18 @@
+package app
+
+import (
+	"context"
+
+	"github.com/go-study-lab/go-mall/config"
+)
+
+// SignedSessionAllowed 开启请求签名后, H5 以外平台的登录会话只能在签名验证通过的请求中使用,
+// 防止APP的Token被拿到后, 在请求头中声明为 H5 绕过签名验证; 上下文中的 appKey 和 platform 由 middleware.VerifySignature 设置
+func SignedSessionAllowed(ctx context.Context, sessionPlatform string) bool {
+	if !config.App().Signature.Enabled || sessionPlatform == "H5" {
+		return true
+	}
+	appKey, _ := ctx.Value("appKey").(string)
+	platform, _ := ctx.Value("platform").(string)
+	return appKey != "" && platform == sessionPlatform
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 列表接口的过滤和排序参数
+//
+//	?sort=-created_at,id&filter[state]=1&filter[bill_money][gte]=100&filter[state][in]=1,2
+//
+// sort 中字段前加 - 表示倒序; filter[字段] 默认是等于, filter[字段][比较方式] 指定比较方式
+// 每个接口用 QueryRule 声明允许过滤和排序的字段, 请求中出现其他字段或者不允许的比较方式时返回参数错误
+// 解析结果通过 FilterScope 和 OrderBy 应用到查询上, 字段名都映射到声明的数据库列, 值都作为SQL参数传递
+
+// QueryFieldType 过滤字段值的类型, 请求中的值会先按类型解析
+type QueryFieldType int
+
+const (
+	QueryString QueryFieldType = iota
+	QueryInt
+	QueryTime // RFC3339 格式的时间或者 2006-01-02 格式的日期
+)
+
+// 过滤时支持的比较方式
+const (
+	OpEq  = "eq"
+	OpNe  = "ne"
+	OpGt  = "gt"
+	OpGte = "gte"
+	OpLt  = "lt"
+	OpLte = "lte"
+	OpIn  = "in" // 多个值用逗号分隔
+)
+
+// maxInValues in 比较最多允许的值的个数
+const maxInValues = 100
+
+// QueryField 允许过滤或排序的字段
+type QueryField struct {
+	Name     string // 请求中的字段名
+	Column   string // 数据库列名, 为空时与 Name 相同
+	Type     QueryFieldType
+	Ops      []string // 允许的比较方式, 为空时不能按这个字段过滤
+	Sortable bool
+}
+
+// QueryRule 列表接口的过滤和排序规则
+type QueryRule struct {
+	Fields []QueryField
+	// DefaultSort 请求中没有 sort 参数时的排序, 格式与 sort 参数相同
+	DefaultSort string
+	// TieBreaker 追加在排序最后的唯一列, 让排序结果稳定, 游标分页也要求排序键唯一, 默认为 id
+	TieBreaker string
+}
+
+type Filter struct {
+	Column string
+	Op     string
+	Values []interface{}
+}
+
+type QuerySpec struct {
+	Filters []Filter
+	Sorts   []clause.OrderByColumn
+}
+
+// NewQuerySpec 按规则解析请求中的过滤和排序参数, 所有不合法的参数一起在 ErrParams 的字段错误中返回
+func NewQuerySpec(c *gin.Context, rule *QueryRule) (*QuerySpec, error) {
+	fields := make(map[string]*QueryField, len(rule.Fields))
+	for i := range rule.Fields {
+		fields[rule.Fields[i].Name] = &rule.Fields[i]
+	}
+	spec := new(QuerySpec)
+	var fieldErrs []errcode.FieldError
+
+	query := c.Request.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	// 字段错误的顺序保持稳定
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		name, op, ok := parseFilterKey(key)
+		field := fields[name]
+		if !ok || field == nil || !containsOp(field.Ops, op) {
+			fieldErrs = append(fieldErrs, errcode.FieldError{Field: key, Rule: "filter"})
+			continue
+		}
+		values, ok := parseFilterValues(field, op, query.Get(key))
+		if !ok {
+			fieldErrs = append(fieldErrs, errcode.FieldError{Field: key, Rule: "filter_value"})
+			continue
+		}
+		spec.Filters = append(spec.Filters, Filter{Column: field.column(), Op: op, Values: values})
+	}
+
+	sortParam, ok := c.GetQuery("sort")
+	if !ok {
+		sortParam = rule.DefaultSort
+	}
+	sorted := make(map[string]bool)
+	for _, item := range strings.Split(sortParam, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name := strings.TrimPrefix(item, "-")
+		field := fields[name]
+		if field == nil || !field.Sortable {
+			fieldErrs = append(fieldErrs, errcode.FieldError{Field: "sort", Rule: "sort"})
+			continue
+		}
+		if sorted[name] {
+			continue
+		}
+		sorted[name] = true
+		spec.Sorts = append(spec.Sorts, clause.OrderByColumn{
+			Column: clause.Column{Name: field.column()},
+			Desc:   strings.HasPrefix(item, "-"),
+		})
+	}
+	if len(fieldErrs) > 0 {
+		return nil, errcode.ErrParams.WithFieldErrors(fieldErrs...)
+	}
+	spec.appendTieBreaker(rule.TieBreaker)
+	return spec, nil
+}
+
+func (f *QueryField) column() string {
+	if f.Column == "" {
+		return f.Name
+	}
+	return f.Column
+}
+
+// appendTieBreaker 排序中没有唯一列时追加, 方向与最后一个排序键相同
+func (spec *QuerySpec) appendTieBreaker(tieBreaker string) {
+	if tieBreaker == "" {
+		tieBreaker = "id"
+	}
+	desc := true
+	for _, s := range spec.Sorts {
+		if s.Column.Name == tieBreaker {
+			return
+		}
+		desc = s.Desc
+	}
+	spec.Sorts = append(spec.Sorts, clause.OrderByColumn{Column: clause.Column{Name: tieBreaker}, Desc: desc})
+}
+
+// parseFilterKey 解析 filter[字段] 和 filter[字段][比较方式]
+func parseFilterKey(key string) (name, op string, ok bool) {
+	rest := strings.TrimPrefix(key, "filter[")
+	if !strings.HasSuffix(rest, "]") {
+		return "", "", false
+	}
+	name, op, found := strings.Cut(strings.TrimSuffix(rest, "]"), "][")
+	if !found {
+		op = OpEq
+	}
+	if name == "" || strings.ContainsAny(name+op, "[]") {
+		return "", "", false
+	}
+	return name, op, true
+}
+
+func containsOp(ops []string, op string) bool {
+	for _, allowed := range ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFilterValues(field *QueryField, op, raw string) ([]interface{}, bool) {
+	items := []string{raw}
+	if op == OpIn {
+		items = strings.Split(raw, ",")
+		if len(items) > maxInValues {
+			return nil, false
+		}
+	}
+	values := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		value, ok := parseFilterValue(field.Type, strings.TrimSpace(item))
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+func parseFilterValue(typ QueryFieldType, raw string) (interface{}, bool) {
+	switch typ {
+	case QueryInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		return n, err == nil
+	case QueryTime:
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, true
+		}
+		t, err := time.ParseInLocation(time.DateOnly, raw, time.Local)
+		return t, err == nil
+	default:
+		return raw, true
+	}
+}
+
+// FilterScope 把过滤条件应用到查询上
+func (spec *QuerySpec) FilterScope() func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, filter := range spec.Filters {
+			db = db.Where(filter.expression())
+		}
+		return db
+	}
+}
+
+func (filter *Filter) expression() clause.Expression {
+	column := clause.Column{Name: filter.Column}
+	switch filter.Op {
+	case OpNe:
+		return clause.Neq{Column: column, Value: filter.Values[0]}
+	case OpGt:
+		return clause.Gt{Column: column, Value: filter.Values[0]}
+	case OpGte:
+		return clause.Gte{Column: column, Value: filter.Values[0]}
+	case OpLt:
+		return clause.Lt{Column: column, Value: filter.Values[0]}
+	case OpLte:
+		return clause.Lte{Column: column, Value: filter.Values[0]}
+	case OpIn:
+		return clause.IN{Column: column, Values: filter.Values}
+	default:
+		return clause.Eq{Column: column, Value: filter.Values[0]}
+	}
+}
+
+// OrderBy 排序键, 最后一个是唯一列; 分页查询时传给 dao.OrderBy, 游标分页按这些键生成游标
+func (spec *QuerySpec) OrderBy() []clause.OrderByColumn {
+	return spec.Sorts
+}
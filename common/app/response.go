@@ -2,19 +2,24 @@ package app
 
 import (
 	"errors"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/i18n"
 	"github.com/go-study-lab/go-mall/common/logger"
 )
 
 type response struct {
-	ctx        *gin.Context
-	Code       int         `json:"code"`
-	Msg        string      `json:"msg"`
-	RequestId  string      `json:"request_id"`
-	Data       interface{} `json:"data,omitempty"`
-	Pagination *pagination `json:"pagination,omitempty"`
+	ctx       *gin.Context
+	Code      int         `json:"code"`
+	Msg       string      `json:"msg"`
+	RequestId string      `json:"request_id"`
+	Data      interface{} `json:"data,omitempty"`
+	// Errors 参数校验失败的字段, 只在参数错误时返回
+	Errors     []errcode.FieldError `json:"errors,omitempty"`
+	Pagination *pagination          `json:"pagination,omitempty"`
 }
 
 func NewResponse(c *gin.Context) *response {
@@ -29,7 +34,7 @@ func (r *response) SetPagination(pagination *pagination) *response {
 
 func (r *response) Success(data interface{}) {
 	r.Code = errcode.Success.Code()
-	r.Msg = errcode.Success.Msg()
+	r.Msg = localizedMsg(r.ctx, errcode.Success)
 	requestId := ""
 	if _, exists := r.ctx.Get("traceid"); exists {
 		val, _ := r.ctx.Get("traceid")
@@ -43,14 +48,12 @@ func (r *response) SuccessOk() {
 	r.Success("")
 }
 
-func (r *response) Error(err *errcode.AppError) {
-	appErr := errcode.ErrServer.Clone() // 生成一个appErr 用作目标错误类型的判定
-	if !errors.As(err, &appErr) {
-		// 如果err不是appErr的类型, 把它变成appErr
-		appErr = appErr.WithCause(err)
-	}
+// Error 输出错误响应, 从错误链中找到预定义错误决定错误码和HTTP状态码, 错误链中的底层错误只记录到日志不返回给客户端
+func (r *response) Error(err error) {
+	appErr := errcode.FromError(err)
 	r.Code = appErr.Code()
-	r.Msg = appErr.Msg()
+	r.Msg = localizedMsg(r.ctx, appErr)
+	r.Errors = localizedFieldErrors(r.ctx, appErr)
 	requestId := ""
 	if _, exists := r.ctx.Get("traceid"); exists {
 		val, _ := r.ctx.Get("traceid")
@@ -58,6 +61,36 @@ func (r *response) Error(err *errcode.AppError) {
 	}
 	r.RequestId = requestId
 	// 兜底记一条响应错误，项目自定义的AppError中有错误链条,方便出错后排查问题
-	logger.Error(r.ctx, "api_resonse_error", "err", err)
+	// 客户端错误记为Warn, 服务端错误记为Error, 控制器里不需要再重复记录
+	if appErr.HttpStatusCode() >= http.StatusInternalServerError {
+		logger.Error(r.ctx, "api_resonse_error", "err", err)
+	} else {
+		logger.Warn(r.ctx, "api_resonse_error", "err", err)
+	}
 	r.ctx.JSON(appErr.HttpStatusCode(), r)
 }
+
+// localizedMsg 按请求协商的语言返回错误信息, 预定义的错误信息按错误码翻译, 被修改过的错误信息原样返回
+func localizedMsg(c *gin.Context, appErr *errcode.AppError) string {
+	if appErr.IsMsgCustomized() {
+		return appErr.Msg()
+	}
+	if msg, ok := i18n.ErrorMsg(i18n.LocaleFromCtx(c), appErr.Code()); ok {
+		return msg
+	}
+	return appErr.Msg()
+}
+
+// localizedFieldErrors 汇总错误上附加的字段错误和错误链中 Validator 的校验错误, 错误信息按请求语言翻译
+func localizedFieldErrors(c *gin.Context, appErr *errcode.AppError) []errcode.FieldError {
+	locale := i18n.LocaleFromCtx(c)
+	var fieldErrs []errcode.FieldError
+	for _, fieldErr := range appErr.FieldErrors() {
+		fieldErrs = append(fieldErrs, i18n.TranslateFieldError(locale, fieldErr))
+	}
+	var validationErrs validator.ValidationErrors
+	if errors.As(appErr.UnWrap(), &validationErrs) {
+		fieldErrs = append(fieldErrs, i18n.ValidationFieldErrors(locale, validationErrs)...)
+	}
+	return fieldErrs
+}
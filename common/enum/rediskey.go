@@ -1,14 +1,24 @@
 package enum
 
-// 项目名:模块名:键名
+// 模块名:键名, 项目前缀由配置 redis.key_prefix 统一添加
+// 同一用户需要在一个命令或事务中操作的多个键, 用 {userId} 作为 hash tag, 保证在 Redis Cluster 中落到同一个 slot
+// 注意: 用户Token和Session的键加上 hash tag 后不再读取旧格式(GOMALL:USER:ACCESS_TOKEN_xxx 等)的键,
+// 升级后所有用户都需要重新登录, 旧的键在各自的过期时间后自动清理
 const (
-	REDIS_KEY_DEMO_ORDER_DETAIL = "GOMALL:DEMO:ORDER_DETAIL_%s"
+	REDIS_KEY_DEMO_ORDER_DETAIL = "DEMO:ORDER_DETAIL_%s"
 )
 
 const (
-	REDIS_KEY_ACCESS_TOKEN       = "GOMALL:USER:ACCESS_TOKEN_%s"
-	REDIS_KEY_REFRESH_TOKEN      = "GOMALL:USER:REFRESH_TOKEN_%s"
-	REDIS_KEY_USER_SESSION       = "GOMALL:USER:SESSION_%d"
-	REDISKEY_TOKEN_REFRESH_LOCK  = "GOMALL:USER:TOKEN_REFRESH_LOCk_%s"
-	REDISKEY_PASSWORDRESET_TOKEN = "GOMALL:USER:PASSWORD_RESET_TOKEN_%s"
+	REDIS_KEY_ACCESS_TOKEN       = "USER:{%d}:ACCESS_TOKEN_%s"
+	REDIS_KEY_REFRESH_TOKEN      = "USER:{%d}:REFRESH_TOKEN_%s"
+	REDIS_KEY_USER_SESSION       = "USER:{%d}:SESSION"
+	REDISKEY_TOKEN_REFRESH_LOCK  = "USER:{%d}:TOKEN_REFRESH_LOCK_%s"
+	REDISKEY_PASSWORDRESET_TOKEN = "USER:PASSWORD_RESET_TOKEN_%s"
+	REDIS_KEY_USER_INFO          = "USER:INFO_%d" // 用户信息旁路缓存
+)
+
+const (
+	REDIS_KEY_DB_RECENT_WRITE = "DB:RECENT_WRITE_%d"     // 用户最近发生过数据库写操作, 用于 read-your-writes
+	REDIS_KEY_IDEMPOTENCY     = "IDEMPOTENCY:{%s}:%s_%s" // 写接口的 Idempotency-Key, 参数依次为调用方、路由和 Idempotency-Key
+	REDIS_KEY_SIGNATURE_NONCE = "SIGNATURE:NONCE_%s_%s"  // 签名请求用过的 nonce, 参数依次为 app-key 和 nonce
 )
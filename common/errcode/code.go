@@ -1,28 +1,41 @@
 package errcode
 
-var codes = map[int]string{}
+import "net/http"
+
+// 业务模块的错误码号段, 新的业务模块先在这里注册号段, 号段不能重叠
+var (
+	commonModule = registerModule("common", 10000000, 10000099, "公共错误码")
+	userModule   = registerModule("user", 10000100, 10000199, "用户模块")
+)
+
+// Success 不属于任何模块
+var Success = register("", 0, "success", http.StatusOK)
 
 // 此处为公共的错误码, 预留 10000000 ~ 10000099 间的 100 个错误码
 var (
-	Success            = newError(0, "success")
-	ErrServer          = newError(10000000, "服务器内部错误")
-	ErrParams          = newError(10000001, "参数错误, 请检查")
-	ErrNotFound        = newError(10000002, "资源未找到")
-	ErrPanic           = newError(10000003, "(*^__^*)系统开小差了,请稍后重试") // 无预期的panic错误
-	ErrToken           = newError(10000004, "Token无效")
-	ErrForbidden       = newError(10000005, "未授权") // 访问一些未授权的资源时的错误
-	ErrTooManyRequests = newError(10000006, "请求过多")
-	ErrCoverData       = newError(10000007, "ConvertDataError") // 数据转换错误
+	ErrServer          = commonModule.newError(10000000, "服务器内部错误", http.StatusInternalServerError)
+	ErrParams          = commonModule.newError(10000001, "参数错误, 请检查", http.StatusBadRequest)
+	ErrNotFound        = commonModule.newError(10000002, "资源未找到", http.StatusNotFound)
+	ErrPanic           = commonModule.newError(10000003, "(*^__^*)系统开小差了,请稍后重试", http.StatusInternalServerError) // 无预期的panic错误
+	ErrToken           = commonModule.newError(10000004, "Token无效", http.StatusUnauthorized)
+	ErrForbidden       = commonModule.newError(10000005, "未授权", http.StatusForbidden) // 访问一些未授权的资源时的错误
+	ErrTooManyRequests = commonModule.newError(10000006, "请求过多", http.StatusTooManyRequests)
+	ErrCoverData       = commonModule.newError(10000007, "ConvertDataError", http.StatusInternalServerError) // 数据转换错误
+	// 幂等请求相关的错误, 见 middleware.Idempotent
+	ErrIdempotencyInFlight  = commonModule.newError(10000008, "请求正在处理中, 请稍后重试", http.StatusConflict)
+	ErrIdempotencyKeyReused = commonModule.newError(10000009, "Idempotency-Key 已被用于其他请求", http.StatusUnprocessableEntity)
+	ErrRequestTooLarge      = commonModule.newError(10000010, "请求体过大", http.StatusRequestEntityTooLarge)
 )
 
-// 各个业务模块自定义的错误码, 从 10000100 开始, 可以按照不同的业务模块划分不同的号段
+// 各个业务模块自定义的错误码, 从 10000100 开始, 每个业务模块注册自己的号段后在号段内定义错误码
 //var (
-//	ErrOrderClosed  = NewError(10000100, "订单已关闭")
+//	orderModule    = registerModule("order", 10000200, 10000299, "订单模块")
+//	ErrOrderClosed = orderModule.newError(10000200, "订单已关闭", http.StatusConflict)
 //)
 
-// 用户模块相关错误码 10000100 ~ 1000199
+// 用户模块相关错误码 10000100 ~ 10000199
 var (
-	ErrUserInvalid      = newError(10000101, "用户异常")
-	ErrUserNameOccupied = newError(10000102, "用户名已被占用")
-	ErrUserNotRight     = newError(10000103, "用户名或密码不正确")
+	ErrUserInvalid      = userModule.newError(10000101, "用户异常", http.StatusForbidden)
+	ErrUserNameOccupied = userModule.newError(10000102, "用户名已被占用", http.StatusConflict)
+	ErrUserNotRight     = userModule.newError(10000103, "用户名或密码不正确", http.StatusUnauthorized)
 )
@@ -2,6 +2,7 @@ package errcode
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"path"
@@ -13,6 +14,12 @@ type AppError struct {
 	msg      string `json:"msg"`
 	cause    error  `json:"cause"`
 	occurred string `json:"occurred"` // 保存由底层错误导致AppErr发生时的位置
+	// httpStatus 返回这个错误时接口响应的HTTP状态码
+	httpStatus int
+	// fieldErrors 参数校验失败的字段
+	fieldErrors []FieldError
+	// customMsg 错误信息被 SetMsg/AppendMsg 修改过, 接口响应时不再按错误码翻译
+	customMsg bool
 }
 
 func (e *AppError) Error() string {
@@ -30,6 +37,13 @@ func (e *AppError) String() string {
 	return e.Error()
 }
 
+// FieldError 参数校验失败的字段, 在接口响应的 errors 中返回给客户端
+type FieldError struct {
+	Field   string `json:"field"`   // 请求中的字段名, 与JSON字段名一致
+	Rule    string `json:"rule"`    // 没有通过的校验规则, 如 required、min、password_complexity
+	Message string `json:"message"` // 为空时接口响应按请求语言翻译校验规则的错误信息
+}
+
 func (e *AppError) Code() int {
 	return e.code
 }
@@ -38,25 +52,22 @@ func (e *AppError) Msg() string {
 	return e.msg
 }
 
+// FieldErrors 返回参数校验失败的字段
+func (e *AppError) FieldErrors() []FieldError {
+	return e.fieldErrors
+}
+
+// IsMsgCustomized 错误信息是否被修改过, 没修改过的预定义错误在接口响应时按请求语言翻译
+func (e *AppError) IsMsgCustomized() bool {
+	return e.customMsg
+}
+
+// HttpStatusCode 接口响应的HTTP状态码, 在定义错误码时指定, Wrap 生成的错误统一按服务器错误处理
 func (e *AppError) HttpStatusCode() int {
-	switch e.Code() {
-	case Success.Code():
-		return http.StatusOK
-	case ErrServer.Code(), ErrPanic.Code():
-		return http.StatusInternalServerError
-	case ErrParams.Code():
-		return http.StatusBadRequest
-	case ErrNotFound.Code():
-		return http.StatusNotFound
-	case ErrTooManyRequests.Code():
-		return http.StatusTooManyRequests
-	case ErrToken.Code():
-		return http.StatusUnauthorized
-	case ErrForbidden.Code():
-		return http.StatusForbidden
-	default:
+	if e.httpStatus == 0 {
 		return http.StatusInternalServerError
 	}
+	return e.httpStatus
 }
 
 // WithCause 在逻辑执行中出现错误, 比如dao层返回的数据库查询错误
@@ -72,20 +83,23 @@ func (e *AppError) WithCause(err error) *AppError {
 
 func (e *AppError) Clone() *AppError {
 	return &AppError{
-		code:     e.code,
-		msg:      e.msg,
-		cause:    e.cause,
-		occurred: e.occurred,
+		code:        e.code,
+		msg:         e.msg,
+		cause:       e.cause,
+		occurred:    e.occurred,
+		httpStatus:  e.httpStatus,
+		fieldErrors: append([]FieldError(nil), e.fieldErrors...),
+		customMsg:   e.customMsg,
 	}
 }
 
-func newError(code int, msg string) *AppError {
-	if code > -1 {
-		if _, duplicated := codes[code]; duplicated {
-			panic(fmt.Sprintf("预定义错误码 %d 不能重复，请检查后更换", code))
-		}
-	}
-	return &AppError{code: code, msg: msg}
+// WithFieldErrors 附加参数校验失败的字段, 用于密码复杂度这类 Validator 之外的自定义校验
+//
+//	errcode.ErrParams.WithFieldErrors(errcode.FieldError{Field: "password", Rule: "password_complexity"})
+func (e *AppError) WithFieldErrors(fieldErrs ...FieldError) *AppError {
+	newErr := e.Clone()
+	newErr.fieldErrors = append(newErr.fieldErrors, fieldErrs...)
+	return newErr
 }
 
 // getAppErrOccurredInfo 获取项目中调用Wrap或者WithCause方法时的程序位置, 方便排查问题
@@ -104,6 +118,7 @@ func getAppErrOccurredInfo() string {
 func (e *AppError) AppendMsg(msg string) *AppError {
 	n := e.Clone()
 	n.msg = fmt.Sprintf("%s, %s", e.msg, msg)
+	n.customMsg = true
 	return n
 }
 
@@ -111,14 +126,16 @@ func (e *AppError) AppendMsg(msg string) *AppError {
 func (e *AppError) SetMsg(msg string) *AppError {
 	n := e.Clone()
 	n.msg = msg
+	n.customMsg = true
 	return n
 }
 
 type formattedErr struct {
-	Code     int         `json:"code"`
-	Msg      string      `json:"msg"`
-	Cause    interface{} `json:"cause"`
-	Occurred string      `json:"occurred"`
+	Code        int          `json:"code"`
+	Msg         string       `json:"msg"`
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+	Cause       interface{}  `json:"cause"`
+	Occurred    string       `json:"occurred"`
 }
 
 // toStructuredError 在JSON Encode 前把Error进行格式化
@@ -126,6 +143,7 @@ func (e *AppError) toStructuredError() *formattedErr {
 	fe := new(formattedErr)
 	fe.Code = e.Code()
 	fe.Msg = e.Msg()
+	fe.FieldErrors = e.fieldErrors
 	fe.Occurred = e.occurred
 	if e.cause != nil {
 		if appErr, ok := e.cause.(*AppError); ok {
@@ -142,7 +160,7 @@ func Wrap(msg string, err error) *AppError {
 	if err == nil {
 		return nil
 	}
-	appErr := &AppError{code: -1, msg: msg, cause: err}
+	appErr := &AppError{code: -1, msg: msg, cause: err, customMsg: true}
 	appErr.occurred = getAppErrOccurredInfo()
 	return appErr
 }
@@ -151,7 +169,12 @@ func (e *AppError) UnWrap() error {
 	return e.cause
 }
 
-// Is 与上面的UnWrap一起让 *AppError 支持 errors.Is(err, target)
+// Unwrap 让标准库的 errors.Is 和 errors.As 能沿着 cause 查找错误链
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// Is 与上面的Unwrap一起让 *AppError 支持 errors.Is(err, target)
 func (e *AppError) Is(target error) bool {
 	targetErr, ok := target.(*AppError)
 	if !ok {
@@ -159,3 +182,18 @@ func (e *AppError) Is(target error) bool {
 	}
 	return targetErr.Code() == e.Code()
 }
+
+// FromError 从错误链中找到最外层的预定义错误, 用于生成接口响应, 错误链中 Wrap 附加的信息不会返回给客户端
+// 错误链中没有预定义错误时按服务器内部错误处理
+func FromError(err error) *AppError {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if appErr, ok := e.(*AppError); ok && appErr != nil {
+			if _, registered := codes[appErr.code]; registered {
+				return appErr
+			}
+		}
+	}
+	appErr := ErrServer.Clone()
+	appErr.cause = err
+	return appErr
+}
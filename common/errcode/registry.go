@@ -0,0 +1,97 @@
+package errcode
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// 错误码注册表, 每个业务模块先注册自己的号段, 再在号段内定义错误码
+// 号段重叠、错误码重复或者超出号段都会在程序启动时 panic, 避免上线后才发现错误码冲突
+
+// ModuleInfo 业务模块的错误码号段
+type ModuleInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+}
+
+// CodeInfo 错误码目录中的一项
+type CodeInfo struct {
+	Code       int    `json:"code"`
+	Module     string `json:"module"`
+	HttpStatus int    `json:"http_status"`
+	Msg        string `json:"msg"`
+}
+
+type module struct {
+	ModuleInfo
+}
+
+var (
+	modules []*module
+	codes   = map[int]CodeInfo{}
+)
+
+// registerModule 注册业务模块的错误码号段 [start, end]
+func registerModule(name string, start, end int, description string) *module {
+	if start <= 0 || start > end {
+		panic(fmt.Sprintf("模块 %s 的错误码号段 %d ~ %d 不正确", name, start, end))
+	}
+	for _, m := range modules {
+		if m.Name == name {
+			panic(fmt.Sprintf("模块 %s 重复注册错误码号段", name))
+		}
+		if start <= m.End && m.Start <= end {
+			panic(fmt.Sprintf("模块 %s 的错误码号段 %d ~ %d 与模块 %s 的号段 %d ~ %d 重叠",
+				name, start, end, m.Name, m.Start, m.End))
+		}
+	}
+	m := &module{ModuleInfo{Name: name, Description: description, Start: start, End: end}}
+	modules = append(modules, m)
+	return m
+}
+
+// newError 在模块的号段内定义错误码, httpStatus 是返回这个错误时接口响应的HTTP状态码
+func (m *module) newError(code int, msg string, httpStatus int) *AppError {
+	if code < m.Start || code > m.End {
+		panic(fmt.Sprintf("错误码 %d 超出了模块 %s 的号段 %d ~ %d", code, m.Name, m.Start, m.End))
+	}
+	return register(m.Name, code, msg, httpStatus)
+}
+
+func register(moduleName string, code int, msg string, httpStatus int) *AppError {
+	if existing, duplicated := codes[code]; duplicated {
+		panic(fmt.Sprintf("预定义错误码 %d 不能重复，已被 %s 模块的 \"%s\" 使用，请检查后更换", code, existing.Module, existing.Msg))
+	}
+	if http.StatusText(httpStatus) == "" {
+		panic(fmt.Sprintf("错误码 %d 的HTTP状态码 %d 不正确", code, httpStatus))
+	}
+	codes[code] = CodeInfo{Code: code, Module: moduleName, HttpStatus: httpStatus, Msg: msg}
+	return &AppError{code: code, msg: msg, httpStatus: httpStatus}
+}
+
+// Modules 返回注册的所有业务模块, 按号段排序
+func Modules() []ModuleInfo {
+	infos := make([]ModuleInfo, 0, len(modules))
+	for _, m := range modules {
+		infos = append(infos, m.ModuleInfo)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Start < infos[j].Start
+	})
+	return infos
+}
+
+// Codes 返回预定义的所有错误码, 按错误码排序
+func Codes() []CodeInfo {
+	infos := make([]CodeInfo, 0, len(codes))
+	for _, info := range codes {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Code < infos[j].Code
+	})
+	return infos
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-study-lab/go-mall/common/errcode"
+)
+
+// 错误码目录, 供客户端团队对照错误码处理错误和展示错误信息
+
+const (
+	CatalogFormatJSON     = "json"
+	CatalogFormatMarkdown = "markdown"
+)
+
+// ErrorCodeEntry 错误码目录中的一项, Messages 是各语言的错误信息
+type ErrorCodeEntry struct {
+	errcode.CodeInfo
+	Messages map[string]string `json:"messages"`
+}
+
+// ErrorCodeCatalog 错误码目录
+type ErrorCodeCatalog struct {
+	Modules []errcode.ModuleInfo `json:"modules"`
+	Codes   []ErrorCodeEntry     `json:"codes"`
+}
+
+// checkErrorCodeTranslations 每个预定义的错误码在每种语言下都要有翻译, 翻译文件中也不能有未定义的错误码
+func checkErrorCodeTranslations() error {
+	var problems []string
+	defined := make(map[int]bool)
+	for _, info := range errcode.Codes() {
+		defined[info.Code] = true
+	}
+	for _, locale := range supportedLocales {
+		errorMsgs := catalogs[locale].Errors
+		for code := range defined {
+			if _, ok := errorMsgs[code]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.yaml 缺少错误码 %d 的翻译", locale, code))
+			}
+		}
+		for code := range errorMsgs {
+			if !defined[code] {
+				problems = append(problems, fmt.Sprintf("%s.yaml 中的错误码 %d 没有预定义", locale, code))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("error code translations are inconsistent: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// GetErrorCodeCatalog 返回包含各语言错误信息的错误码目录
+func GetErrorCodeCatalog() *ErrorCodeCatalog {
+	catalog := &ErrorCodeCatalog{Modules: errcode.Modules()}
+	for _, info := range errcode.Codes() {
+		entry := ErrorCodeEntry{CodeInfo: info, Messages: make(map[string]string, len(supportedLocales))}
+		for _, locale := range supportedLocales {
+			entry.Messages[locale], _ = ErrorMsg(locale, info.Code)
+		}
+		catalog.Codes = append(catalog.Codes, entry)
+	}
+	return catalog
+}
+
+// WriteErrorCodeCatalog 把错误码目录按 json 或者 markdown 格式写入 w
+func WriteErrorCodeCatalog(w io.Writer, format string) error {
+	catalog := GetErrorCodeCatalog()
+	switch format {
+	case CatalogFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(catalog)
+	case CatalogFormatMarkdown:
+		return catalog.writeMarkdown(w)
+	default:
+		return fmt.Errorf("unsupported error code catalog format: %s", format)
+	}
+}
+
+// writeMarkdown 每个模块一张表格, 不属于任何模块的错误码(success)放在最前面
+func (catalog *ErrorCodeCatalog) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# 错误码\n")
+	sections := append([]errcode.ModuleInfo{{Description: "通用"}}, catalog.Modules...)
+	for _, m := range sections {
+		var entries []ErrorCodeEntry
+		for _, entry := range catalog.Codes {
+			if entry.Module == m.Name {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if m.Name == "" {
+			fmt.Fprintf(&b, "\n## %s\n\n", m.Description)
+		} else {
+			fmt.Fprintf(&b, "\n## %s (%s: %d ~ %d)\n\n", m.Description, m.Name, m.Start, m.End)
+		}
+		b.WriteString("| 错误码 | HTTP状态码 |")
+		for _, locale := range supportedLocales {
+			fmt.Fprintf(&b, " %s |", locale)
+		}
+		b.WriteString("\n| --- | --- |" + strings.Repeat(" --- |", len(supportedLocales)) + "\n")
+		for _, entry := range entries {
+			fmt.Fprintf(&b, "| %d | %d |", entry.Code, entry.HttpStatus)
+			for _, locale := range supportedLocales {
+				fmt.Fprintf(&b, " %s |", strings.ReplaceAll(entry.Messages[locale], "|", "\\|"))
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,148 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 接口响应信息的国际化, 各语言的翻译放在 locales/ 下, 编译时内嵌到二进制中
+// 客户端通过请求头 Accept-Language 指定语言, 不支持的语言使用默认语言
+
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+	// DefaultLocale 请求没有指定语言或者指定的语言不支持时使用的语言
+	DefaultLocale = ZhCN
+	// LocaleKey 上下文中保存请求语言的键, 由 middleware.Locale 设置
+	LocaleKey = "locale"
+)
+
+// supportedLocales 支持的语言, 与 locales/ 下的文件对应
+var supportedLocales = []string{ZhCN, EnUS}
+
+//go:embed locales/*.yaml
+var localeFiles embed.FS
+
+type catalog struct {
+	Errors      map[int]string    `yaml:"errors"`      // 错误码 --> 错误信息
+	Validations map[string]string `yaml:"validations"` // 自定义校验规则 --> 错误信息
+}
+
+var catalogs = map[string]*catalog{}
+
+func init() {
+	for _, locale := range supportedLocales {
+		content, err := localeFiles.ReadFile("locales/" + locale + ".yaml")
+		if err != nil {
+			panic(err)
+		}
+		c := new(catalog)
+		if err = yaml.Unmarshal(content, c); err != nil {
+			panic(fmt.Sprintf("parse locale file %s.yaml error: %v", locale, err))
+		}
+		catalogs[locale] = c
+	}
+	if err := checkErrorCodeTranslations(); err != nil {
+		panic(err)
+	}
+}
+
+// SupportedLocales 返回支持的语言
+func SupportedLocales() []string {
+	return append([]string(nil), supportedLocales...)
+}
+
+// ErrorMsg 获取错误码在指定语言下的错误信息, 没有翻译时返回false
+func ErrorMsg(locale string, code int) (string, bool) {
+	c, ok := catalogs[locale]
+	if !ok {
+		c = catalogs[DefaultLocale]
+	}
+	msg, ok := c.Errors[code]
+	return msg, ok
+}
+
+// ValidationMsg 获取自定义校验规则在指定语言下的错误信息, 没有翻译时返回false
+func ValidationMsg(locale, rule, field string) (string, bool) {
+	c, ok := catalogs[locale]
+	if !ok {
+		c = catalogs[DefaultLocale]
+	}
+	msg, ok := c.Validations[rule]
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(msg, "{0}", field), true
+}
+
+// LocaleFromCtx 获取上下文中请求的语言
+func LocaleFromCtx(ctx context.Context) string {
+	if ctx != nil {
+		if locale, ok := ctx.Value(LocaleKey).(string); ok && locale != "" {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// Negotiate 按请求头 Accept-Language 中的语言和权重选择支持的语言
+// 例如 "en-GB,en;q=0.9,zh;q=0.8" 选择 en-US, 没有可用的语言时返回默认语言
+func Negotiate(acceptLanguage string) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+	for _, t := range tags {
+		if locale, ok := matchLocale(t.name); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// matchLocale 先完整匹配, 再按主语言匹配, 如 zh-TW 匹配 zh-CN, en 匹配 en-US
+func matchLocale(name string) (string, bool) {
+	if name == "*" {
+		return DefaultLocale, true
+	}
+	name = strings.ReplaceAll(name, "_", "-")
+	for _, locale := range supportedLocales {
+		if strings.EqualFold(locale, name) {
+			return locale, true
+		}
+	}
+	language, _, _ := strings.Cut(name, "-")
+	for _, locale := range supportedLocales {
+		if supported, _, _ := strings.Cut(locale, "-"); strings.EqualFold(supported, language) {
+			return locale, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package i18n
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"github.com/go-study-lab/go-mall/common/errcode"
+)
+
+// 参数校验错误的翻译, 使用 validator 自带的各语言翻译, 注册到 gin 的校验器上
+// 校验错误中的字段名使用请求中的字段名(json/form标签), 而不是结构体的字段名
+
+var validationTranslators = map[string]ut.Translator{}
+
+func init() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	validate.RegisterTagNameFunc(requestFieldName)
+	uni := ut.New(zh.New(), zh.New(), en.New())
+	zhTrans, _ := uni.GetTranslator("zh")
+	enTrans, _ := uni.GetTranslator("en")
+	if err := zhTranslations.RegisterDefaultTranslations(validate, zhTrans); err != nil {
+		panic(err)
+	}
+	if err := enTranslations.RegisterDefaultTranslations(validate, enTrans); err != nil {
+		panic(err)
+	}
+	validationTranslators[ZhCN] = zhTrans
+	validationTranslators[EnUS] = enTrans
+}
+
+// TranslateValidationError 把单个字段的校验错误翻译成指定语言
+func TranslateValidationError(locale string, fieldErr validator.FieldError) string {
+	trans, ok := validationTranslators[locale]
+	if !ok {
+		trans, ok = validationTranslators[DefaultLocale]
+	}
+	if !ok {
+		return fieldErr.Error()
+	}
+	return fieldErr.Translate(trans)
+}
+
+// ValidationFieldErrors 把参数校验错误转换成接口响应中的字段错误, 错误信息翻译成指定语言
+func ValidationFieldErrors(locale string, errs validator.ValidationErrors) []errcode.FieldError {
+	fieldErrs := make([]errcode.FieldError, 0, len(errs))
+	for _, fieldErr := range errs {
+		fieldErrs = append(fieldErrs, errcode.FieldError{
+			Field:   fieldPath(fieldErr),
+			Rule:    fieldErr.Tag(),
+			Message: TranslateValidationError(locale, fieldErr),
+		})
+	}
+	return fieldErrs
+}
+
+// TranslateFieldError 翻译自定义校验的字段错误, 已经有错误信息或者没有翻译的规则原样返回
+func TranslateFieldError(locale string, fieldErr errcode.FieldError) errcode.FieldError {
+	if fieldErr.Message != "" {
+		return fieldErr
+	}
+	if msg, ok := ValidationMsg(locale, fieldErr.Rule, fieldErr.Field); ok {
+		fieldErr.Message = msg
+	}
+	return fieldErr
+}
+
+// fieldPath 去掉命名空间开头的结构体类型名, 嵌套字段返回 address.city 这样的路径
+// 匿名结构体的命名空间没有类型名, 这时两种命名空间的第一段不同
+func fieldPath(fieldErr validator.FieldError) string {
+	root, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	structRoot, _, _ := strings.Cut(fieldErr.StructNamespace(), ".")
+	if !found || root != structRoot {
+		return fieldErr.Namespace()
+	}
+	return path
+}
+
+// requestFieldName 按 json、form 标签的顺序取请求中的字段名, 都没有设置时使用结构体字段名
+func requestFieldName(field reflect.StructField) string {
+	for _, tagName := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tagName), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// 运行时调整日志级别, 排查线上问题时不用重启服务
+
+// SetLevel 动态设置日志级别, level 可选值: debug, info, warn, error
+func SetLevel(level string) error {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	_level.SetLevel(lvl)
+	return nil
+}
+
+// GetLevel 获取当前的日志级别
+func GetLevel() string {
+	return _level.Level().String()
+}
+
+// isForceDebug 请求是否开启了强制Debug日志, 由中间件验证请求头签名后在上下文中设置
+func isForceDebug(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	forceDebug, _ := ctx.Value("forcedebug").(bool)
+	return forceDebug
+}
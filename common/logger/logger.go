@@ -17,19 +17,26 @@ var (
 )
 
 type facade struct {
-	_logger *zap.Logger
+	_logger      *zap.Logger
+	_debugLogger *zap.Logger
 }
 
 func (f *facade) log(ctx context.Context, lvl zapcore.Level, msg string, kv ...interface{}) {
 	fields := makeLogFields(ctx, kv...)
-	ce := f._logger.Check(lvl, msg)
+	l := f._logger
+	if isForceDebug(ctx) {
+		// 请求开启了强制Debug日志, 不受全局日志级别的限制
+		l = f._debugLogger
+	}
+	ce := l.Check(lvl, msg)
 	ce.Write(fields...)
 }
 
 func logFacade() *facade {
 	once.Do(func() {
 		f = &facade{
-			_logger: _logger,
+			_logger:      _logger,
+			_debugLogger: _debugLogger,
 		}
 	})
 	return f
@@ -68,7 +75,8 @@ func makeLogFields(ctx context.Context, kv ...interface{}) []zap.Field {
 	fields := make([]zap.Field, 0, len(kv)/2)
 	for i := 0; i < len(kv); i += 2 {
 		k := fmt.Sprintf("%v", kv[i])
-		value := kv[i+1]
+		// 命中脱敏规则的字段, 值在写入日志前先做脱敏
+		value, _ := redactField(k, kv[i+1])
 		fields = append(fields, convertToZapField(k, value))
 	}
 	return fields
@@ -130,6 +138,9 @@ func convertToZapField(k string, value interface{}) zap.Field {
 		return zap.Uint(k, v)
 	case float32:
 		return zap.Float32(k, v)
+	case error:
+		// 错误链条中的 cause 可能带有请求参数等敏感信息
+		return zap.String(k, RedactString(v.Error()))
 	default:
 		return zap.Any(k, v)
 	}
@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/go-study-lab/go-mall/common/util"
+	"github.com/go-study-lab/go-mall/config"
+)
+
+// 日志脱敏, 避免密码、Token、验证码这类敏感信息被明文写入日志文件
+
+const redactedValue = "******"
+
+// 内置的脱敏规则, 配置文件 app.log.redact 中的规则会追加到这里
+var defaultRedactRules = []config.RedactRule{
+	{Key: "password"},
+	{Key: "password_confirm"},
+	{Key: "password_reset_code"},
+	{Key: "access_token", Mask: "token"},
+	{Key: "refresh_token", Mask: "token"},
+	{Key: "password_reset_token", Mask: "token"},
+}
+
+var defaultRedactHeaders = []string{"user-token", "admin-token", "debug-log-sign"}
+
+type redactor struct {
+	keys    map[string]string // 字段名 --> mask方式, 匹配任意层级的同名字段
+	paths   map[string]string // 字段路径(如 data.access_token) --> mask方式
+	headers map[string]struct{}
+	// 无法按JSON解析的文本, 用正则匹配 "key":"value", key=value 和 Header: value 形式的内容
+	textPatterns []*regexp.Regexp
+}
+
+var _redactor *redactor
+
+func init() {
+	_redactor = newRedactor(
+		append(defaultRedactRules, config.App().Log.Redact.Keys...),
+		append(defaultRedactHeaders, config.App().Log.Redact.Headers...),
+	)
+}
+
+func newRedactor(rules []config.RedactRule, headers []string) *redactor {
+	r := &redactor{
+		keys:    make(map[string]string),
+		paths:   make(map[string]string),
+		headers: make(map[string]struct{}),
+	}
+	for _, rule := range rules {
+		key := strings.ToLower(rule.Key)
+		if strings.Contains(key, ".") {
+			r.paths[key] = rule.Mask
+			// 文本形式的内容里没有层级信息, 只能按字段名匹配
+			key = key[strings.LastIndex(key, ".")+1:]
+		} else {
+			r.keys[key] = rule.Mask
+		}
+		quoted := regexp.QuoteMeta(key)
+		r.textPatterns = append(r.textPatterns,
+			regexp.MustCompile(`(?i)("`+quoted+`"\s*:\s*")([^"]*)(")`),
+			regexp.MustCompile(`(?i)(\b`+quoted+`=)([^&\s"]*)()`),
+		)
+	}
+	for _, header := range headers {
+		header = strings.ToLower(header)
+		r.headers[header] = struct{}{}
+		r.textPatterns = append(r.textPatterns,
+			regexp.MustCompile(`(?im)(^`+regexp.QuoteMeta(header)+`:\s*)([^\r\n]*)()`),
+		)
+	}
+	return r
+}
+
+// RedactString 对要写入日志的内容做脱敏, 能按JSON解析的按字段脱敏, 否则按文本规则脱敏
+func RedactString(content string) string {
+	return _redactor.redactString(content)
+}
+
+// RedactJSON 对JSON格式的请求体、响应体做脱敏
+func RedactJSON(content []byte) string {
+	return _redactor.redactString(string(content))
+}
+
+// RedactQuery 对URL中的查询参数做脱敏
+func RedactQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return _redactor.redactText(rawQuery)
+	}
+	redacted := false
+	for key, vals := range values {
+		mask, ok := _redactor.keys[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		for i, v := range vals {
+			vals[i] = maskValue(mask, v)
+		}
+		redacted = true
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// RedactHeaders 对请求头做脱敏, 返回脱敏后的副本
+func RedactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if _, ok := _redactor.headers[strings.ToLower(key)]; ok {
+			value = redactedValue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+func (r *redactor) redactString(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+		var data interface{}
+		if err := decoder.Decode(&data); err == nil {
+			data = r.redactJSONValue("", "", data)
+			buf := new(bytes.Buffer)
+			encoder := json.NewEncoder(buf)
+			encoder.SetEscapeHTML(false)
+			if err = encoder.Encode(data); err == nil {
+				return strings.TrimRight(buf.String(), "\n")
+			}
+		}
+	}
+	return r.redactText(content)
+}
+
+func (r *redactor) redactText(content string) string {
+	for _, pattern := range r.textPatterns {
+		content = pattern.ReplaceAllString(content, "${1}"+redactedValue+"${3}")
+	}
+	return content
+}
+
+// redactJSONValue 递归遍历JSON, 按字段名或者字段路径对命中的值做脱敏
+func (r *redactor) redactJSONValue(path, key string, value interface{}) interface{} {
+	if key != "" {
+		mask, hit := r.paths[path]
+		if !hit {
+			mask, hit = r.keys[key]
+		}
+		if hit {
+			if s, ok := value.(string); ok {
+				return maskValue(mask, s)
+			}
+			// 非字符串的值(数字验证码等)整个替换掉
+			return redactedValue
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			lk := strings.ToLower(k)
+			childPath := lk
+			if path != "" {
+				childPath = path + "." + lk
+			}
+			v[k] = r.redactJSONValue(childPath, lk, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			// 数组元素沿用数组字段的路径
+			v[i] = r.redactJSONValue(path, "", item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redactField 日志门面中按 key 传入的值命中脱敏规则时, 返回脱敏后的值
+func redactField(key string, value interface{}) (interface{}, bool) {
+	mask, hit := _redactor.keys[strings.ToLower(key)]
+	if !hit {
+		return value, false
+	}
+	if s, ok := value.(string); ok {
+		return maskValue(mask, s), true
+	}
+	return redactedValue, true
+}
+
+// maskValue 按照规则的mask方式对值做脱敏, 部分脱敏复用 util.Mask* 系列方法
+func maskValue(mask, value string) string {
+	if value == "" {
+		return value
+	}
+	switch mask {
+	case "phone":
+		return util.MaskPhone(value)
+	case "email":
+		return util.MaskEmail(value)
+	case "login_name":
+		return util.MaskLoginName(value)
+	case "real_name":
+		return util.MaskRealName(value)
+	case "token":
+		if len(value) > 6 {
+			return value[:6] + redactedValue
+		}
+		return redactedValue
+	default:
+		return redactedValue
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"os"
 
 	"github.com/go-study-lab/go-mall/common/enum"
@@ -10,35 +11,75 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var _logger *zap.Logger
+var (
+	_logger *zap.Logger
+	// _debugLogger 与 _logger 共用输出, 但是不受 _level 限制, 用于单个请求强制输出Debug日志
+	_debugLogger *zap.Logger
+	// _level 日志级别, 可以在运行时动态调整
+	_level zap.AtomicLevel
+)
 
 func init() {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	encoder := zapcore.NewJSONEncoder(encoderConfig)
 	fileWriteSyncer := getFileLogWriter()
-	var cores []zapcore.Core
-	switch config.App.Env {
+	var writeSyncers []zapcore.WriteSyncer
+	_level = zap.NewAtomicLevelAt(defaultLevel())
+	switch config.App().Env {
 	case enum.ModeTest, enum.ModeProd:
 		// 测试环境和生产环境的日志输出到文件中
-		cores = append(cores, zapcore.NewCore(encoder, fileWriteSyncer, zapcore.InfoLevel))
+		writeSyncers = append(writeSyncers, fileWriteSyncer)
 	case enum.ModeDev:
-		// 开发环境同时向控制台和文件输出日志，Debug级别的日志也会被输出
-		cores = append(cores,
-			zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
-			zapcore.NewCore(encoder, fileWriteSyncer, zapcore.DebugLevel),
-		)
+		// 开发环境同时向控制台和文件输出日志
+		writeSyncers = append(writeSyncers, zapcore.AddSync(os.Stdout), fileWriteSyncer)
+	}
+	// 配置文件中设置了日志级别时以配置为准
+	if config.App().Log.Level != "" {
+		if err := _level.UnmarshalText([]byte(config.App().Log.Level)); err != nil {
+			panic(err)
+		}
+	}
+	var cores, debugCores []zapcore.Core
+	for _, ws := range writeSyncers {
+		cores = append(cores, zapcore.NewCore(encoder, ws, _level))
+		debugCores = append(debugCores, zapcore.NewCore(encoder, ws, zapcore.DebugLevel))
+	}
+	_logger = zap.New(zapcore.NewTee(cores...))
+	_debugLogger = zap.New(zapcore.NewTee(debugCores...))
+	// 配置热更新时, 只有配置中的日志级别变化了才调整, 避免其他配置的变更覆盖通过管理接口设置的级别
+	// 配置中去掉日志级别时恢复成环境的默认级别
+	configuredLevel := config.App().Log.Level
+	config.OnChange(func() {
+		level := config.App().Log.Level
+		if level == configuredLevel {
+			return
+		}
+		configuredLevel = level
+		if level == "" {
+			_level.SetLevel(defaultLevel())
+			return
+		}
+		if err := SetLevel(level); err != nil {
+			Error(context.Background(), "LOG_LEVEL_RELOAD_ERROR", "level", level, "err", err)
+		}
+	})
+}
+
+// defaultLevel 配置中没有设置日志级别时的默认级别, 开发环境为Debug, 测试环境和生产环境为Info
+func defaultLevel() zapcore.Level {
+	if config.App().Env == enum.ModeDev {
+		return zapcore.DebugLevel
 	}
-	core := zapcore.NewTee(cores...)
-	_logger = zap.New(core)
+	return zapcore.InfoLevel
 }
 
 func getFileLogWriter() (writeSyncer zapcore.WriteSyncer) {
 	// 使用 lmberjack 实现 logger rotate
 	lumberJackLogger := &lumberjack.Logger{
-		Filename:  config.App.Log.FilePath,
-		MaxSize:   config.App.Log.FileMaxSize,      // 文件最大100M
-		MaxAge:    config.App.Log.BackUpFileMaxAge, // 备份文件最多保存90天
+		Filename:  config.App().Log.FilePath,
+		MaxSize:   config.App().Log.FileMaxSize,      // 文件最大100M
+		MaxAge:    config.App().Log.BackUpFileMaxAge, // 备份文件最多保存90天
 		Compress:  false,
 		LocalTime: true,
 	}
@@ -48,7 +89,7 @@ func getFileLogWriter() (writeSyncer zapcore.WriteSyncer) {
 // test
 func ZapLoggerTest() {
 	_logger.Info("test for zap init",
-		zap.Any("app", config.App),
+		zap.Any("app", config.App()),
 		zap.Any("database", config.Database),
 		zap.Any("data", "快乐池塘栽种了梦想就变成海洋\n鼓的眼睛大嘴巴同样唱的响亮\n借我一双小翅膀就能飞向太阳\n我相信奇迹就在身上\n啦......\n有你相伴 leap frog\n啦......\n自信成长有你相伴 leap frog\n快乐的一只小青蛙 leap frog\n快乐的一只小青蛙 leap frog\n(rap)快乐的池塘里面有只小青蛙\n它跳起舞来就像被王子附体了\n酷酷的眼神,没有哪只青蛙能比美\n总有一天它会被公主唤醒了"),
 	)
@@ -1,9 +1,13 @@
 package middleware
 
 import (
+	"crypto/subtle"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/config"
 	"github.com/go-study-lab/go-mall/logic/domainservice"
 )
 
@@ -27,9 +31,30 @@ func AuthUser() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if !app.SignedSessionAllowed(c, tokenVerify.Platform) {
+			logger.Warn(c, "SESSION_PLATFORM_UNVERIFIED", "user_id", tokenVerify.UserId, "platform", tokenVerify.Platform)
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
 		c.Set("userId", tokenVerify.UserId)
 		c.Set("sessionId", tokenVerify.SessionId)
 		c.Set("platform", tokenVerify.Platform)
+		restoreRecentDBWrite(c, tokenVerify.UserId)
+		c.Next()
+	}
+}
+
+// AuthAdmin 管理接口的认证中间件, 请求头 admin-token 需要与配置中的 app.admin.token 一致
+func AuthAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("admin-token")
+		adminToken := config.App().Admin.Token
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
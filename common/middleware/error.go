@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/app"
+)
+
+// HandleErrors 把控制器和中间件通过 c.Error 记录的错误转换成统一的错误响应
+// 已经输出过响应的请求不再处理, 多个错误时以最后一个为准
+func HandleErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeErrors(c)
+	}
+}
+
+// writeErrors 输出 c.Errors 中记录的错误, 路由上的中间件需要拿到错误响应时(比如 Idempotent)可以提前输出
+func writeErrors(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	app.NewResponse(c).Error(c.Errors.Last().Err)
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/app"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/config"
+	"github.com/go-study-lab/go-mall/dal/cache"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端为每次写操作生成唯一的值(比如UUID), 重试时携带相同的值
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader 响应是重放的第一次请求的响应时, 响应头中带上 Idempotent-Replayed: true
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen     = 64
+)
+
+var errInvalidIdempotencyKey = errcode.ErrParams.WithFieldErrors(errcode.FieldError{Field: IdempotencyKeyHeader, Rule: "idempotency_key"})
+
+// Idempotent 写接口的幂等中间件, 防止客户端重试注册、创建订单这类请求时重复执行
+// 第一次请求的响应(状态码和响应体)按 用户/路由/Idempotency-Key 保存在Redis中, 重复的请求直接返回保存的响应;
+// 第一次请求还在处理中时返回 ErrIdempotencyInFlight, 相同的 Idempotency-Key 用于请求体不同的请求时返回 ErrIdempotencyKeyReused;
+// 第一次请求出现服务端错误(5xx)时不保存响应, 客户端可以用相同的 Idempotency-Key 重试
+// 没有携带 Idempotency-Key 的请求不做处理; 需要认证的接口要放在 AuthUser 之后, 这样才能按用户区分,
+// 未登录的请求按平台和客户端IP区分; 请求体超过 app.idempotency.max_body_size 时返回 ErrRequestTooLarge
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+		if idempotencyKey == "" {
+			c.Next()
+			return
+		}
+		if len(idempotencyKey) > maxIdempotencyKeyLen {
+			app.NewResponse(c).Error(errInvalidIdempotencyKey)
+			c.Abort()
+			return
+		}
+		body, err := readBody(c, config.App().Idempotency.MaxBodySize)
+		if err != nil {
+			app.NewResponse(c).Error(err)
+			c.Abort()
+			return
+		}
+		bodyHash := sha256.Sum256(body)
+
+		idempotency := cache.NewIdempotency(idempotencyCaller(c), c.Request.Method+" "+c.FullPath(), idempotencyKey)
+		acquired, err := idempotency.Acquire(c, hex.EncodeToString(bodyHash[:]), config.App().Idempotency.ProcessingTTL)
+		if err != nil {
+			// 无法保证幂等时不执行写操作, 由客户端稍后重试
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+			c.Abort()
+			return
+		}
+		if !acquired {
+			replayIdempotent(c, idempotency, hex.EncodeToString(bodyHash[:]))
+			c.Abort()
+			return
+		}
+
+		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		c.Writer = blw
+		c.Next()
+		// 错误响应原本由 HandleErrors 在所有中间件执行完后输出, 这里提前输出才能保存下来
+		writeErrors(c)
+		if status := c.Writer.Status(); status >= http.StatusInternalServerError {
+			err = idempotency.Release(c)
+		} else {
+			err = idempotency.Complete(c, status, c.Writer.Header().Get("Content-Type"), blw.body.String(), config.App().Idempotency.TTL)
+		}
+		if err != nil {
+			logger.Error(c, "IDEMPOTENCY_SAVE_ERROR", "idempotency_key", idempotencyKey, "err", err)
+		}
+	}
+}
+
+// idempotencyCaller 登录用户按用户ID区分, 未登录的调用方(如注册接口)按平台和客户端IP区分,
+// 避免不同客户端碰巧使用了相同的 Idempotency-Key 时拿到别人的响应
+func idempotencyCaller(c *gin.Context) string {
+	if userId := c.GetInt64("userId"); userId != 0 {
+		return strconv.FormatInt(userId, 10)
+	}
+	return "anonymous:" + c.GetHeader("platform") + ":" + c.ClientIP()
+}
+
+// replayIdempotent 处理携带了已经用过的 Idempotency-Key 的请求
+func replayIdempotent(c *gin.Context, idempotency *cache.Idempotency, bodyHash string) {
+	record, err := idempotency.Record(c)
+	switch {
+	case err != nil:
+		app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+	case record == nil:
+		// 第一次请求失败或者处理中的标记刚好过期, 让客户端稍后重试
+		app.NewResponse(c).Error(errcode.ErrIdempotencyInFlight)
+	case record.BodyHash != bodyHash:
+		app.NewResponse(c).Error(errcode.ErrIdempotencyKeyReused)
+	case !record.Completed():
+		app.NewResponse(c).Error(errcode.ErrIdempotencyInFlight)
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(record.Status, record.ContentType, []byte(record.Body))
+	}
+}
@@ -2,18 +2,24 @@ package middleware
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/errcode"
 	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/util"
+	"github.com/go-study-lab/go-mall/config"
 )
 
 type bodyLogWriter struct {
@@ -30,6 +36,21 @@ func (w bodyLogWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// readBody 读取不超过 limit 字节的请求体并放回 c.Request.Body, 让后面的处理程序还能读取
+// 超过 limit 时返回 ErrRequestTooLarge
+func readBody(c *gin.Context, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, errcode.ErrRequestTooLarge.WithCause(err)
+		}
+		return nil, errcode.ErrParams.WithCause(err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
 // infrastructure 中存放项目运行需要的基础中间件
 func StartTrace() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -46,6 +67,40 @@ func StartTrace() gin.HandlerFunc {
 	}
 }
 
+// ForceDebugLog 请求头中携带了合法的 debug-log-sign 时, 为这次请求强制输出Debug级别的日志
+// 请求头格式: debug-log-sign: {过期时间戳}.{签名}, 签名为 HmacSHA256({traceid}.{过期时间戳}, app.log.debug_secret)
+// 签名只对请求头 traceid 指定的链路有效, 过期时间不能超过 app.log.debug_max_ttl 之后
+// 这样排查生产环境的问题时不需要调整全局日志级别, 也不用重启服务; 需要在 StartTrace 之后使用
+func ForceDebugLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if verifyDebugLogSign(c.GetString("traceid"), c.Request.Header.Get("debug-log-sign")) {
+			c.Set("forcedebug", true)
+		}
+		c.Next()
+	}
+}
+
+func verifyDebugLogSign(traceId, debugSign string) bool {
+	logConf := config.App().Log
+	if debugSign == "" || logConf.DebugSignSecret == "" || traceId == "" {
+		return false
+	}
+	expireAt, sign, found := strings.Cut(debugSign, ".")
+	if !found {
+		return false
+	}
+	expireTimestamp, err := strconv.ParseInt(expireAt, 10, 64)
+	if err != nil {
+		return false
+	}
+	if ttl := time.Until(time.Unix(expireTimestamp, 0)); ttl < 0 || ttl > logConf.DebugSignMaxTTL {
+		// 签名已过期, 或者过期时间太远
+		return false
+	}
+	expected := util.HmacSHA256Hex(traceId+"."+expireAt, logConf.DebugSignSecret)
+	return hmac.Equal([]byte(expected), []byte(sign))
+}
+
 func LogAccess() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 保存body
@@ -65,9 +120,13 @@ func LogAccess() gin.HandlerFunc {
 
 func accessLog(c *gin.Context, accessType string, dur time.Duration, body []byte, dataOut interface{}) {
 	req := c.Request
-	bodyStr := string(body)
-	query := req.URL.RawQuery
+	// 请求体、查询参数和响应中的密码、Token等敏感信息脱敏后再记录
+	bodyStr := logger.RedactJSON(body)
+	query := logger.RedactQuery(req.URL.RawQuery)
 	path := req.URL.Path
+	if output, ok := dataOut.(string); ok {
+		dataOut = logger.RedactString(output)
+	}
 	// TODO: 实现Token认证后再把访问日志里也加上token记录
 	// token := c.Request.Header.Get("token")
 	logger.Info(c, "AccessLog",
@@ -97,15 +156,16 @@ func GinPanicRecovery() gin.HandlerFunc {
 						}
 					}
 				}
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
+				dumpRequest, _ := httputil.DumpRequest(c.Request, false)
+				httpRequest := logger.RedactString(string(dumpRequest))
 				if brokenPipe {
-					logger.Error(c, "http request broken pipe", "path", c.Request.URL.Path, "error", err, "request", string(httpRequest))
+					logger.Error(c, "http request broken pipe", "path", c.Request.URL.Path, "error", err, "request", httpRequest)
 					// If the connection is dead, we can't write a status to it.
 					c.Error(err.(error)) // nolint: errcheck
 					c.Abort()
 					return
 				}
-				logger.Error(c, "http_request_panic", "path", c.Request.URL.Path, "error", err, "request", string(httpRequest), "stack", string(debug.Stack()))
+				logger.Error(c, "http_request_panic", "path", c.Request.URL.Path, "error", err, "request", httpRequest, "stack", string(debug.Stack()))
 				c.AbortWithError(http.StatusInternalServerError, err.(error))
 			}
 		}()
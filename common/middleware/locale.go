@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/i18n"
+)
+
+// Locale 按请求头 Accept-Language 协商响应使用的语言, 保存到上下文中供错误响应翻译错误信息
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		c.Set(i18n.LocaleKey, locale)
+		c.Header("Content-Language", locale)
+		c.Next()
+	}
+}
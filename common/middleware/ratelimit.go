@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/app"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/config"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit 全局限流中间件, 令牌桶的速率和容量来自配置 app.rate_limit, 支持配置热更新
+func RateLimit() gin.HandlerFunc {
+	limiter := rate.NewLimiter(rate.Limit(config.App().RateLimit.QPS), config.App().RateLimit.Burst)
+	config.OnChange(func() {
+		limiter.SetLimit(rate.Limit(config.App().RateLimit.QPS))
+		limiter.SetBurst(config.App().RateLimit.Burst)
+	})
+	return func(c *gin.Context) {
+		if !config.App().RateLimit.Enabled {
+			c.Next()
+			return
+		}
+		if !limiter.Allow() {
+			app.NewResponse(c).Error(errcode.ErrTooManyRequests)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
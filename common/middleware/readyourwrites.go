@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/config"
+	"github.com/go-study-lab/go-mall/dal/cache"
+	"github.com/go-study-lab/go-mall/dal/dao"
+)
+
+// restoredDBWriteKey 上下文中记录从Redis恢复写标记的时间
+const restoredDBWriteKey = "restoredDBWriteAt"
+
+// ReadYourWrites 为请求开启 read-your-writes, 请求中发生数据库写操作后, 后续的读操作走主库
+// 通过认证的用户请求会把写标记按用户ID保存到Redis, 同一用户在时间窗口内的后续请求也会读主库
+// 写标记在 AuthUser 认证通过后才恢复, 未认证的请求不会查询Redis
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		marker := dao.NewWriteMarker()
+		c.Set(dao.ReadYourWritesKey, marker)
+		start := time.Now()
+		c.Next()
+		// 只有这次请求中发生了写操作才刷新用户的写标记, 恢复的标记不延长时间窗口
+		userId := c.GetInt64("userId")
+		lastWriteAt := marker.LastWriteAt()
+		if userId != 0 && lastWriteAt.After(start) && lastWriteAt.After(c.GetTime(restoredDBWriteKey)) {
+			if err := cache.SetUserRecentDBWrite(c, userId, config.Database.ReadYourWritesWindow); err != nil {
+				logger.Error(c, "ReadYourWritesError", "err", err)
+			}
+		}
+	}
+}
+
+// restoreRecentDBWrite 用户在时间窗口内发生过写操作时恢复请求的写标记, 由 AuthUser 在认证通过后调用
+func restoreRecentDBWrite(c *gin.Context, userId int64) {
+	marker := dao.WriteMarkerFromCtx(c)
+	if marker == nil {
+		return
+	}
+	recentWrite, err := cache.HasUserRecentDBWrite(c, userId)
+	if err != nil {
+		logger.Error(c, "ReadYourWritesError", "err", err)
+	}
+	if recentWrite {
+		marker.Mark()
+		c.Set(restoredDBWriteKey, marker.LastWriteAt())
+	}
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/app"
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/common/util"
+	"github.com/go-study-lab/go-mall/config"
+	"github.com/go-study-lab/go-mall/dal/cache"
+)
+
+// 请求签名相关的请求头
+const (
+	SignAppKeyHeader    = "app-key"
+	SignTimestampHeader = "timestamp" // 秒级Unix时间戳
+	SignNonceHeader     = "nonce"     // 每个请求唯一的随机字符串, 8到64个字符
+	SignatureHeader     = "signature"
+)
+
+// VerifySignature 验证APP和合作方客户端的请求签名, 防止请求被伪造和重放, 签名方式:
+//
+//	signature = HmacSHA256Hex(StringToSign, 客户端密钥)
+//	StringToSign = Method + "\n" + Path + "\n" + 按参数名排序的查询参数 + "\n" +
+//		app-key + "\n" + timestamp + "\n" + nonce + "\n" + SHA256Hex(请求体)
+//
+// 请求头 platform 为 APP 或者携带了 app-key 的请求必须签名, H5 的请求不做处理;
+// timestamp 与服务端时间的误差不能超过 app.signature.clock_skew, 同一个 nonce 不能重复使用
+// 验证失败时返回 ErrForbidden, 请求体超过 app.signature.max_body_size 时返回 ErrRequestTooLarge, 需要在 APIVersion 之后使用
+//
+// 开启签名后请求的平台以这里设置到上下文中的 platform 为准: 签名验证通过的请求使用客户端配置的平台,
+// 未签名的请求不会是 APP; 登录、刷新Token和 AuthUser 不再信任请求头 platform, 见 app.SignedSessionAllowed
+func VerifySignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signConf := config.App().Signature
+		appKey := c.GetHeader(SignAppKeyHeader)
+		if !signConf.Enabled {
+			c.Next()
+			return
+		}
+		platform := c.GetHeader("platform")
+		if appKey == "" && platform != "APP" {
+			c.Set("platform", platform)
+			c.Next()
+			return
+		}
+		reason, err := verifySignature(c, appKey)
+		if err != nil {
+			app.NewResponse(c).Error(err)
+			c.Abort()
+			return
+		}
+		if reason != "" {
+			logger.Warn(c, "REQUEST_SIGNATURE_INVALID", "app_key", appKey, "reason", reason)
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
+		// 验证通过后再记录 nonce, 避免伪造的请求占用 nonce
+		nonce := c.GetHeader(SignNonceHeader)
+		fresh, err := cache.UseSignatureNonce(c, appKey, nonce, 2*signConf.ClockSkew)
+		if err != nil {
+			app.NewResponse(c).Error(errcode.ErrServer.WithCause(err))
+			c.Abort()
+			return
+		}
+		if !fresh {
+			logger.Warn(c, "REQUEST_SIGNATURE_INVALID", "app_key", appKey, "reason", "nonce replayed")
+			app.NewResponse(c).Error(errcode.ErrForbidden)
+			c.Abort()
+			return
+		}
+		if client := signConf.Clients[strings.ToLower(appKey)]; client.Platform != "" {
+			platform = client.Platform
+		}
+		c.Set("appKey", appKey)
+		c.Set("platform", platform)
+		c.Next()
+	}
+}
+
+// verifySignature 验证签名, 返回验证失败的原因, 只用于记录日志, 不返回给客户端; 读取请求体失败时返回错误
+func verifySignature(c *gin.Context, appKey string) (string, error) {
+	signConf := config.App().Signature
+	client, ok := signConf.Clients[strings.ToLower(appKey)]
+	if !ok {
+		return "unknown app-key", nil
+	}
+	if client.Platform != "" && c.GetHeader("platform") != client.Platform {
+		return "platform mismatch", nil
+	}
+	timestamp := c.GetHeader(SignTimestampHeader)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "invalid timestamp", nil
+	}
+	if skew := time.Since(time.Unix(signedAt, 0)); skew > signConf.ClockSkew || skew < -signConf.ClockSkew {
+		return "timestamp out of clock skew", nil
+	}
+	nonce := c.GetHeader(SignNonceHeader)
+	if len(nonce) < 8 || len(nonce) > 64 {
+		return "invalid nonce", nil
+	}
+	body, err := readBody(c, signConf.MaxBodySize)
+	if err != nil {
+		return "", err
+	}
+	// 接口版本协商会改写 URL.Path, 签名使用客户端实际请求的路径
+	requestURI, err := url.ParseRequestURI(c.Request.RequestURI)
+	if err != nil {
+		return "invalid request uri", nil
+	}
+	bodyHash := sha256.Sum256(body)
+	stringToSign := strings.Join([]string{
+		c.Request.Method,
+		requestURI.EscapedPath(),
+		requestURI.Query().Encode(),
+		appKey,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	if !hmac.Equal([]byte(util.HmacSHA256Hex(stringToSign, client.Secret)), []byte(c.GetHeader(SignatureHeader))) {
+		return "signature mismatch", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/app"
+	"github.com/go-study-lab/go-mall/common/errcode"
+)
+
+// APIVersionHeader 客户端通过这个请求头指定接口版本, 值为 v2 或者 2, 响应中用同名响应头返回实际使用的版本
+const APIVersionHeader = "api-version"
+
+var errUnsupportedAPIVersion = errcode.ErrParams.WithFieldErrors(errcode.FieldError{Field: APIVersionHeader, Rule: "api_version"})
+
+// APIVersion 接口版本协商, versions 是支持的版本, 如 v1、v2, prefixes 是按版本注册的路由前缀, 如 /user/
+// 路径中带版本号的请求(/v2/user/login)直接按路径中的版本处理;
+// 未带版本号的请求如果指定了请求头 api-version, 转到对应版本的路由上处理, 如 /user/login 按 /v2/user/login 处理;
+// 未带版本号也没有指定版本的请求按原来的路由处理, 老版本的APP不需要做任何修改
+// 需要在 StartTrace 和 Locale 之后, 其他全局中间件之前使用, 转发后的请求会重新执行全部中间件
+func APIVersion(engine *gin.Engine, versions []string, prefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if version, _, found := strings.Cut(strings.TrimPrefix(path, "/"), "/"); found && slices.Contains(versions, version) {
+			c.Set("apiVersion", version)
+			c.Header(APIVersionHeader, version)
+			c.Next()
+			return
+		}
+		header := c.GetHeader(APIVersionHeader)
+		if header == "" || !slices.ContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(path, prefix) }) {
+			c.Next()
+			return
+		}
+		version := "v" + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(header)), "v")
+		if !slices.Contains(versions, version) {
+			app.NewResponse(c).Error(errUnsupportedAPIVersion)
+			c.Abort()
+			return
+		}
+		c.Request.URL.Path = "/" + version + path
+		if c.Request.URL.RawPath != "" {
+			c.Request.URL.RawPath = "/" + version + c.Request.URL.RawPath
+		}
+		// HandleContext 会重置 c 并重新路由, 转发后的请求会再执行一遍 StartTrace 和 Locale:
+		// 请求头中没有 traceid 时第二次会重新生成, 日志和响应中用的都是第二次生成的 trace ID,
+		// 第一次生成的还没有被任何地方使用, 所以这个中间件之前不能有记录日志的中间件
+		engine.HandleContext(c)
+		c.Abort()
+	}
+}
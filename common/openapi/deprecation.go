@@ -0,0 +1,32 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/logger"
+)
+
+// deprecation 给废弃接口的响应加上 Deprecation(RFC 9745)、Sunset(RFC 8594) 和指向新接口的 Link 响应头
+// 并记录调用日志, 按 platform 和 User-Agent 统计还在调用废弃接口的客户端版本
+func deprecation(r *route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// RFC 9745 中的值是 Structured Fields 的 Date, 格式为 @ 加上 Unix 时间戳
+		c.Header("Deprecation", "@"+strconv.FormatInt(r.op.DeprecatedAt.Unix(), 10))
+		if !r.op.Sunset.IsZero() {
+			c.Header("Sunset", r.op.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if r.op.Successor != "" {
+			c.Header("Link", "<"+r.op.Successor+">; rel="+strconv.Quote("successor-version"))
+		}
+		logger.Warn(c, "DEPRECATED_API_CALLED",
+			"method", r.method,
+			"path", r.path,
+			"platform", c.GetHeader("platform"),
+			"user_agent", c.Request.UserAgent(),
+			"sunset", r.op.Sunset,
+		)
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package openapi
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:generate go run ./internal/fetchswaggerui -version 5.17.14 -dir swaggerui
+
+//go:embed swagger.html
+var swaggerHTML string
+
+// swaggerUIFiles swagger-ui-dist 的静态资源, 由 go generate 下载到 swaggerui 目录后编译到二进制中
+//
+//go:embed swaggerui
+var swaggerUIFiles embed.FS
+
+var swaggerTemplate = template.Must(template.New("swagger").Parse(swaggerHTML))
+
+// SpecHandler 输出 OpenAPI 文档, 文档在第一次请求时生成, 之后不再变化
+func SpecHandler(info Info) gin.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc = Build(info)
+		})
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// SwaggerUIHandler 用 Swagger UI 展示 specURL 的文档, 页面和静态资源都编译在二进制中, assetsURL 是 SwaggerUIAssetsHandler 的路由前缀
+// 静态资源还没有下载时提示先执行 go generate
+func SwaggerUIHandler(title, specURL, assetsURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := fs.Stat(swaggerUIFiles, "swaggerui/swagger-ui-bundle.js"); err != nil {
+			c.String(http.StatusServiceUnavailable, "Swagger UI assets are missing, run: go generate ./common/openapi")
+			return
+		}
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = swaggerTemplate.Execute(c.Writer, map[string]string{"Title": title, "SpecURL": specURL, "AssetsURL": assetsURL})
+	}
+}
+
+// SwaggerUIAssetsHandler 输出 Swagger UI 的静态资源, 路由中用 *file 匹配文件名
+func SwaggerUIAssetsHandler() gin.HandlerFunc {
+	assets, _ := fs.Sub(swaggerUIFiles, "swaggerui")
+	return func(c *gin.Context) {
+		c.FileFromFS(c.Param("file"), http.FS(assets))
+	}
+}
@@ -0,0 +1,54 @@
+// fetchswaggerui 下载 swagger-ui-dist 中 Swagger UI 页面需要的静态资源, 由 openapi 包的 go generate 调用
+//
+//	go generate ./common/openapi
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// assets 页面用到的文件, LICENSE 随资源一起分发
+var assets = []string{"swagger-ui.css", "swagger-ui-bundle.js", "LICENSE"}
+
+func main() {
+	version := flag.String("version", "", "swagger-ui-dist version")
+	dir := flag.String("dir", "swaggerui", "output directory")
+	flag.Parse()
+	if *version == "" {
+		log.Fatal("fetchswaggerui: -version is required")
+	}
+	client := &http.Client{Timeout: time.Minute}
+	for _, name := range assets {
+		url := fmt.Sprintf("https://unpkg.com/swagger-ui-dist@%s/%s", *version, name)
+		if err := download(client, url, filepath.Join(*dir, name)); err != nil {
+			log.Fatalf("fetchswaggerui: %s: %v", url, err)
+		}
+		log.Printf("fetchswaggerui: %s", url)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "VERSION"), []byte(*version+"\n"), 0o644); err != nil {
+		log.Fatalf("fetchswaggerui: %v", err)
+	}
+}
+
+func download(client *http.Client, url, path string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
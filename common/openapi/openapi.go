@@ -0,0 +1,349 @@
+// Package openapi 根据路由登记的接口信息和 request/reply 结构体生成 OpenAPI 3 文档
+//
+// 路由通过 Group 注册, 注册路由的同时登记接口文档, 请求和响应的结构体按 json/form/header/uri 标签
+// 和 binding 校验规则生成 Schema, 响应统一包在 app.response 的 code/msg/data 结构中
+//
+//	g := openapi.NewGroup(rg.Group("/user/"), "用户")
+//	g.POST("login", openapi.Operation{
+//		Summary: "登录",
+//		Body:    request.UserLogin{}.Body,
+//		Reply:   reply.TokenReply{},
+//	}, app.Handle(controller.LoginUser))
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-study-lab/go-mall/common/app"
+)
+
+const Version = "3.0.3"
+
+// idempotencyHeader 幂等写接口的请求头, 重试时携带与第一次请求相同的值
+type idempotencyHeader struct {
+	IdempotencyKey string `header:"Idempotency-Key" binding:"omitempty,max=64"`
+}
+
+// Operation 接口的文档信息, Path/Query/Header/Body/Reply 传结构体的零值
+type Operation struct {
+	Summary     string
+	Description string
+	// Security 接口需要的认证方式, 对应 RegisterSecurityScheme 登记的名字
+	Security []string
+	Path     interface{} // uri 标签对应路径参数
+	Query    interface{} // form 标签对应查询参数
+	Header   interface{} // header 标签对应请求头
+	Body     interface{} // json 标签对应 JSON 请求体
+	Reply    interface{} // 成功响应中的 data
+	// Paginated 支持分页的列表接口, 文档中加上分页参数和响应中的分页信息
+	Paginated bool
+	// Idempotent 使用了 middleware.Idempotent 的写接口, 文档中加上 Idempotency-Key 请求头
+	Idempotent bool
+	// Deprecated 废弃的接口, 响应中带上 Deprecation 响应头并记录调用日志, 方便统计还有哪些客户端在调用
+	Deprecated bool
+	// DeprecatedAt 接口废弃的时间, 通过 Deprecation 响应头告知客户端, 废弃的接口必须设置
+	DeprecatedAt time.Time
+	// Sunset 废弃的接口计划下线的时间, 通过 Sunset 响应头告知客户端
+	Sunset time.Time
+	// Successor 替代这个接口的新接口的路径, 通过 Link 响应头告知客户端
+	Successor string
+}
+
+type route struct {
+	method string
+	path   string
+	tag    string
+	hidden bool
+	op     Operation
+}
+
+type securityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+var (
+	routes          []*route
+	securitySchemes = map[string]*securityScheme{}
+)
+
+// RegisterSecurityScheme 登记通过请求头传递的认证方式
+func RegisterSecurityScheme(name, header, description string) {
+	securitySchemes[name] = &securityScheme{Type: "apiKey", In: "header", Name: header, Description: description}
+}
+
+// Group 包装 gin.RouterGroup, 注册路由时同时登记接口文档
+type Group struct {
+	group  *gin.RouterGroup
+	tag    string
+	hidden bool
+}
+
+// NewGroup tag 是文档中接口的分组名
+func NewGroup(g *gin.RouterGroup, tag string) *Group {
+	return &Group{group: g, tag: tag}
+}
+
+// Hide 分组内的接口不出现在文档中, 用于测试接口和文档自身的接口
+func (g *Group) Hide() *Group {
+	g.hidden = true
+	return g
+}
+
+// Handle 注册路由并登记接口文档, 废弃的接口会在处理请求前先输出废弃相关的响应头
+func (g *Group) Handle(method, relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	r := &route{
+		method: method,
+		path:   joinPaths(g.group.BasePath(), relativePath),
+		tag:    g.tag,
+		hidden: g.hidden,
+		op:     op,
+	}
+	if op.Deprecated {
+		if op.DeprecatedAt.IsZero() {
+			// 注册路由时就能发现的程序错误, 直接panic
+			panic(fmt.Sprintf("openapi: deprecated route %s %s has no DeprecatedAt", r.method, r.path))
+		}
+		handlers = append([]gin.HandlerFunc{deprecation(r)}, handlers...)
+	}
+	g.group.Handle(method, relativePath, handlers...)
+	routes = append(routes, r)
+}
+
+func (g *Group) GET(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodGet, relativePath, op, handlers...)
+}
+
+func (g *Group) POST(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodPost, relativePath, op, handlers...)
+}
+
+func (g *Group) PUT(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodPut, relativePath, op, handlers...)
+}
+
+func (g *Group) PATCH(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodPatch, relativePath, op, handlers...)
+}
+
+func (g *Group) DELETE(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	g.Handle(http.MethodDelete, relativePath, op, handlers...)
+}
+
+// joinPaths 与 gin 拼接路由路径的方式一致, 保留末尾的 /
+func joinPaths(basePath, relativePath string) string {
+	if relativePath == "" {
+		return basePath
+	}
+	finalPath := path.Join(basePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
+
+// CheckRoutes 对比 gin 中注册的路由和登记了文档的路由, 绕过 Group 直接注册到 gin 上的路由视为文档缺失
+func CheckRoutes(registered gin.RoutesInfo) error {
+	documented := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		documented[r.method+" "+r.path] = true
+	}
+	var problems []string
+	for _, r := range registered {
+		key := r.Method + " " + r.Path
+		if !documented[key] {
+			problems = append(problems, "路由没有登记文档: "+key)
+		}
+		delete(documented, key)
+	}
+	for key := range documented {
+		problems = append(problems, "登记了文档的路由没有注册: "+key)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi: routes and spec drift: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Info 文档的基本信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Tags       []tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type tag struct {
+	Name string `json:"name"`
+}
+
+type components struct {
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes,omitempty"`
+}
+
+type operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []*parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+// Build 按登记的路由生成 OpenAPI 文档
+func Build(info Info) *Document {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]map[string]*operation{},
+		Components: components{SecuritySchemes: securitySchemes},
+	}
+	tags := map[string]bool{}
+	for _, r := range routes {
+		if r.hidden {
+			continue
+		}
+		if !tags[r.tag] {
+			tags[r.tag] = true
+			doc.Tags = append(doc.Tags, tag{Name: r.tag})
+		}
+		p := openAPIPath(r.path)
+		if doc.Paths[p] == nil {
+			doc.Paths[p] = map[string]*operation{}
+		}
+		doc.Paths[p][strings.ToLower(r.method)] = buildOperation(r)
+	}
+	return doc
+}
+
+// openAPIPath 把 gin 的路径参数 :id 和 *path 转换成 {id} 和 {path}
+func openAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func buildOperation(r *route) *operation {
+	op := &operation{
+		Tags:        []string{r.tag},
+		Summary:     r.op.Summary,
+		Description: r.op.Description,
+		Deprecated:  r.op.Deprecated,
+		Responses: map[string]*response{
+			"200": {
+				Description: "成功",
+				Content:     jsonContent(envelopeSchema(r.op.Reply, r.op.Paginated)),
+			},
+			"default": {
+				Description: "错误, code 和 msg 见错误码目录 /doc/error-codes, 参数错误时 errors 中是校验失败的字段",
+				Content:     jsonContent(errorEnvelopeSchema()),
+			},
+		},
+	}
+	op.Parameters = append(op.Parameters, parameters(r.op.Path, "uri", "path")...)
+	op.Parameters = append(op.Parameters, parameters(r.op.Query, "form", "query")...)
+	op.Parameters = append(op.Parameters, parameters(r.op.Header, "header", "header")...)
+	if r.op.Paginated {
+		op.Parameters = append(op.Parameters, parameters(app.PaginationQuery{}, "form", "query")...)
+	}
+	if r.op.Idempotent {
+		op.Parameters = append(op.Parameters, parameters(idempotencyHeader{}, "header", "header")...)
+	}
+	if r.op.Body != nil {
+		op.RequestBody = &requestBody{Required: true, Content: jsonContent(schemaOf(reflect.TypeOf(r.op.Body)))}
+	}
+	for _, name := range r.op.Security {
+		op.Security = append(op.Security, map[string][]string{name: {}})
+	}
+	return op
+}
+
+func jsonContent(s *Schema) map[string]*mediaType {
+	return map[string]*mediaType{"application/json": {Schema: s}}
+}
+
+// parameters 把结构体的字段转换成参数, 路径参数都是必填的
+func parameters(v interface{}, tagName, in string) []*parameter {
+	if v == nil {
+		return nil
+	}
+	t := indirect(reflect.TypeOf(v))
+	var params []*parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := fieldName(field, tagName)
+		if !field.IsExported() || !ok {
+			continue
+		}
+		s := schemaOf(field.Type)
+		required := applyBinding(s, field, t)
+		params = append(params, &parameter{Name: name, In: in, Required: required || in == "path", Schema: s})
+	}
+	return params
+}
+
+// envelopeSchema 成功响应的结构, data 替换成接口返回的数据, 没有分页时去掉 pagination
+func envelopeSchema(reply interface{}, paginated bool) *Schema {
+	s := schemaOf(reflect.TypeOf(app.NewResponse(nil)).Elem())
+	delete(s.Properties, "errors")
+	if reply == nil {
+		delete(s.Properties, "data")
+	} else {
+		s.Properties["data"] = schemaOf(reflect.TypeOf(reply))
+	}
+	if !paginated {
+		delete(s.Properties, "pagination")
+	}
+	return s
+}
+
+func errorEnvelopeSchema() *Schema {
+	s := schemaOf(reflect.TypeOf(app.NewResponse(nil)).Elem())
+	delete(s.Properties, "data")
+	delete(s.Properties, "pagination")
+	return s
+}
@@ -0,0 +1,244 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema OpenAPI 3.0 的 Schema Object, 只包含项目用到的部分
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinItems             *int64             `json:"minItems,omitempty"`
+	MaxItems             *int64             `json:"maxItems,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// e164Pattern 与 validator 的 e164 规则一致
+const e164Pattern = `^\+[1-9]?[0-9]{7,14}$`
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf 按 json 标签和 binding 标签生成类型的 Schema
+func schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+	s := new(Schema)
+	switch {
+	case t == timeType:
+		s.Type, s.Format = "string", "date-time"
+	case t.Kind() == reflect.Struct:
+		s.Type = "object"
+		s.Properties = map[string]*Schema{}
+		addProperties(s, t)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s.Type, s.Format = "string", "byte"
+		} else {
+			s.Type, s.Items = "array", schemaOf(t.Elem())
+		}
+	case t.Kind() == reflect.Map:
+		s.Type, s.AdditionalProperties = "object", schemaOf(t.Elem())
+	case t.Kind() == reflect.Interface:
+		// 任意类型
+	default:
+		s.Type, s.Format = scalarType(t.Kind())
+	}
+	s.Nullable = nullable && s.Type != ""
+	return s
+}
+
+func scalarType(kind reflect.Kind) (typ, format string) {
+	switch kind {
+	case reflect.Bool:
+		return "boolean", ""
+	case reflect.Int64, reflect.Uint64:
+		return "integer", "int64"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "integer", "int32"
+	case reflect.Float32:
+		return "number", "float"
+	case reflect.Float64:
+		return "number", "double"
+	default:
+		return "string", ""
+	}
+}
+
+// addProperties 把结构体的导出字段加到 Schema 中, 匿名嵌入的结构体字段展开到上一层
+func addProperties(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, ok := fieldName(field, "json")
+		if !ok {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && indirect(field.Type).Kind() == reflect.Struct {
+			addProperties(s, indirect(field.Type))
+			continue
+		}
+		property := schemaOf(field.Type)
+		if required := applyBinding(property, field, t); required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = property
+	}
+}
+
+// fieldName 取字段在请求或响应中的名字, 标签为 "-" 的字段不出现在文档中
+func fieldName(field reflect.StructField, tagName string) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get(tagName), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		return field.Name, true
+	}
+	return name, true
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// applyBinding 把字段的 binding 校验规则转换成 Schema 的约束, 返回字段是否必填
+// 用 | 连接的规则(如 e164|email)满足其一即可, 转换成 anyOf
+func applyBinding(s *Schema, field reflect.StructField, parent reflect.Type) (required bool) {
+	kind := indirect(field.Type).Kind()
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			required = true
+			continue
+		}
+		alternatives := strings.Split(rule, "|")
+		if len(alternatives) == 1 {
+			applyRule(s, kind, rule, parent)
+			continue
+		}
+		for _, alternative := range alternatives {
+			sub := new(Schema)
+			applyRule(sub, kind, alternative, parent)
+			s.AnyOf = append(s.AnyOf, sub)
+		}
+	}
+	return required
+}
+
+func applyRule(s *Schema, kind reflect.Kind, rule string, parent reflect.Type) {
+	name, param, _ := strings.Cut(rule, "=")
+	switch name {
+	case "email":
+		s.Format = "email"
+	case "e164":
+		s.Pattern = e164Pattern
+	case "url", "uri":
+		s.Format = "uri"
+	case "uuid":
+		s.Format = "uuid"
+	case "ip":
+		s.Format = "ip"
+	case "oneof":
+		for _, value := range strings.Fields(param) {
+			s.Enum = append(s.Enum, enumValue(kind, value))
+		}
+	case "len":
+		setMin(s, kind, param, false)
+		setMax(s, kind, param, false)
+	case "min", "gte":
+		setMin(s, kind, param, false)
+	case "gt":
+		setMin(s, kind, param, true)
+	case "max", "lte":
+		setMax(s, kind, param, false)
+	case "lt":
+		setMax(s, kind, param, true)
+	case "eqfield":
+		if field, ok := parent.FieldByName(param); ok {
+			param, _ = fieldName(field, "json")
+		}
+		s.Description = strings.TrimSpace(s.Description + " 必须与 " + param + " 相同")
+	}
+}
+
+func enumValue(kind reflect.Kind, value string) interface{} {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// setMin 字符串限制长度, 切片和Map限制元素个数, 数字限制大小
+func setMin(s *Schema, kind reflect.Kind, param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch kind {
+	case reflect.String:
+		length := int64(n)
+		if exclusive {
+			length++
+		}
+		s.MinLength = &length
+	case reflect.Slice, reflect.Array, reflect.Map:
+		items := int64(n)
+		if exclusive {
+			items++
+		}
+		s.MinItems = &items
+	default:
+		s.Minimum, s.ExclusiveMinimum = &n, exclusive
+	}
+}
+
+func setMax(s *Schema, kind reflect.Kind, param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch kind {
+	case reflect.String:
+		length := int64(n)
+		if exclusive {
+			length--
+		}
+		s.MaxLength = &length
+	case reflect.Slice, reflect.Array, reflect.Map:
+		items := int64(n)
+		if exclusive {
+			items--
+		}
+		s.MaxItems = &items
+	default:
+		s.Maximum, s.ExclusiveMaximum = &n, exclusive
+	}
+}
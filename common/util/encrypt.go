@@ -7,6 +7,7 @@ import (
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	cryptoRand "crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -14,6 +15,7 @@ import (
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
+	"io"
 )
 
 // AesEncrypt AES加密  ｜ key长度为 16 字节才能加密成功
@@ -30,6 +32,41 @@ func AesEncrypt(origData, key []byte) ([]byte, error) {
 	return encrypted, nil
 }
 
+// AesGcmEncrypt AES-GCM 认证加密, 密文被篡改时解密会失败 ｜ key长度为 16、24 或 32 字节
+// 返回结果为 nonce + 密文, 解密时从中取出 nonce
+func AesGcmEncrypt(origData, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(cryptoRand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, origData, nil), nil
+}
+
+// AesGcmDecrypt 解密 AesGcmEncrypt 加密的数据
+func AesGcmDecrypt(crypted, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(crypted) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, crypted[:nonceSize], crypted[nonceSize:], nil)
+}
+
 func PKCS5Padding(ciphertext []byte, blockSize int) []byte {
 	padding := blockSize - len(ciphertext)%blockSize
 	padText := bytes.Repeat([]byte{byte(padding)}, padding)
@@ -42,11 +79,14 @@ func AesDecrypt(crypted, key []byte) ([]byte, error) {
 		return nil, err
 	}
 	blockSize := block.BlockSize()
+	if len(crypted) == 0 || len(crypted)%blockSize != 0 {
+		// 密文长度不对时 CryptBlocks 会 panic
+		return nil, errors.New("aes decrypt: ciphertext is not a multiple of the block size")
+	}
 	blockMode := cipher.NewCBCDecrypter(block, key[:blockSize])
 	origData := make([]byte, len(crypted))
 	blockMode.CryptBlocks(origData, crypted)
-	origData = PKCS5UnPadding(origData)
-	return origData, nil
+	return PKCS5UnPaddingChecked(origData)
 }
 
 func PKCS5UnPadding(origData []byte) []byte {
@@ -59,6 +99,19 @@ func PKCS5UnPadding(origData []byte) []byte {
 	return origData[:(length - unPadding)]
 }
 
+// PKCS5UnPaddingChecked 与 PKCS5UnPadding 相同, 但是填充不合法(比如密文被篡改或者密钥不对)时返回错误, 解密客户端传来的数据时使用
+func PKCS5UnPaddingChecked(origData []byte) ([]byte, error) {
+	length := len(origData)
+	if length == 0 {
+		return nil, errors.New("pkcs5 unpadding: empty data")
+	}
+	unPadding := int(origData[length-1])
+	if unPadding < 1 || unPadding > length {
+		return nil, errors.New("pkcs5 unpadding: invalid padding")
+	}
+	return origData[:(length - unPadding)], nil
+}
+
 // RsaSignPKCS1v15 对消息的散列值进行数字签名
 func RsaSignPKCS1v15(msg, privateKey []byte, hashType crypto.Hash) ([]byte, error) {
 	block, _ := pem.Decode(privateKey)
@@ -103,3 +156,10 @@ func SHA256HashBytes(stringMessage string) []byte {
 	bytes := hash.Sum(nil)
 	return bytes
 }
+
+// HmacSHA256Hex 使用key对消息计算HMAC-SHA256, 返回16进制编码的签名
+func HmacSHA256Hex(message, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
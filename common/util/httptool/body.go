@@ -0,0 +1,97 @@
+package httptool
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+)
+
+// MultipartFile multipart/form-data 请求中上传的文件
+type MultipartFile struct {
+	FieldName string    // 表单字段名
+	FileName  string    // 文件名
+	Reader    io.Reader // 文件内容
+}
+
+type multipartBody struct {
+	boundary string
+	fields   map[string]string
+	files    []MultipartFile
+}
+
+func newMultipartBody(fields map[string]string, files []MultipartFile) *multipartBody {
+	return &multipartBody{
+		// 提前确定分隔符, 请求头中的 Content-Type 需要用到
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		fields:   fields,
+		files:    files,
+	}
+}
+
+func (mb *multipartBody) contentType() string {
+	return "multipart/form-data; boundary=" + mb.boundary
+}
+
+// reader 通过管道边读文件边写请求体, 避免大文件整个读入内存
+func (mb *multipartBody) reader() io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		mw := multipart.NewWriter(pw)
+		pw.CloseWithError(mb.write(mw))
+	}()
+	return pr
+}
+
+func (mb *multipartBody) write(mw *multipart.Writer) error {
+	if err := mw.SetBoundary(mb.boundary); err != nil {
+		return err
+	}
+	for name, value := range mb.fields {
+		if err := mw.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	for _, file := range mb.files {
+		part, err := mw.CreateFormFile(file.FieldName, file.FileName)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(part, file.Reader); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// requestBody 每次发送请求时创建请求体
+func (opts *requestOption) requestBody() io.Reader {
+	switch {
+	case opts.multipart != nil:
+		return opts.multipart.reader()
+	case opts.bodyReader != nil:
+		return opts.bodyReader
+	default:
+		return bytes.NewReader(opts.data)
+	}
+}
+
+// replayable 请求体能否重复发送, 决定请求失败后能否重试
+func (opts *requestOption) replayable() bool {
+	return opts.multipart == nil && opts.bodyReader == nil
+}
+
+// logBody 日志中记录的请求体, 流式的请求体不记录
+func (opts *requestOption) logBody() string {
+	if !opts.replayable() && opts.multipart == nil {
+		return "Stream data, skip logging"
+	}
+	return formatLogContent(opts.data, opts.headers)
+}
+
+// logReply 日志中记录的响应体, 流式写出的响应体不记录
+func (opts *requestOption) logReply(respBody []byte) string {
+	if opts.respWriter != nil && respBody == nil {
+		return "Stream data, skip logging"
+	}
+	return formatLogContent(respBody, opts.headers)
+}
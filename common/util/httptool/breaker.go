@@ -0,0 +1,83 @@
+package httptool
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 对Host的请求连续失败触发熔断, 熔断期间的请求直接返回这个错误, 不再请求对方
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker 按Host统计连续失败次数的熔断器
+// closed: 正常放行; 连续失败达到阈值后 open: 拒绝所有请求;
+// 熔断时间过后 half-open: 只放行一个探测请求, 成功则 closed, 失败则重新 open
+type breaker struct {
+	mu          sync.Mutex
+	state       int
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	return &breaker{threshold: threshold, openTimeout: openTimeout}
+}
+
+// allow 请求前检查是否放行
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// 探测请求还没有结果
+		return false
+	default:
+		return true
+	}
+}
+
+// record 记录请求结果
+func (b *breaker) record(success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.state, b.failures = breakerClosed, 0
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state, b.openedAt = breakerOpen, time.Now()
+	}
+}
+
+// abandon 请求被调用方取消, 结果不计入统计; 探测请求被取消时允许立即发起下一个探测请求
+func (b *breaker) abandon() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.state, b.openedAt = breakerOpen, time.Now().Add(-b.openTimeout)
+	}
+}
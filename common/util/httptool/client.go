@@ -0,0 +1,80 @@
+package httptool
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-study-lab/go-mall/config"
+)
+
+// hostClient 每个Host使用单独的连接池配置、超时重试配置和熔断器
+type hostClient struct {
+	client  *http.Client
+	option  config.HttpClientOption
+	breaker *breaker
+}
+
+var (
+	_Client     *http.Client
+	hostClients sync.Map // host --> *hostClient
+)
+
+// getHostClient 获取Host对应的Client, 没有单独配置的Host使用默认配置, 但是熔断器按Host区分
+func getHostClient(host, hostname string) *hostClient {
+	if hc, ok := hostClients.Load(host); ok {
+		return hc.(*hostClient)
+	}
+	option := hostOption(host, hostname)
+	hc := &hostClient{
+		option:  option,
+		breaker: newBreaker(*option.BreakerFailures, option.BreakerOpenTimeout),
+		client:  &http.Client{Transport: newTransport(option)},
+	}
+	actual, _ := hostClients.LoadOrStore(host, hc)
+	return actual.(*hostClient)
+}
+
+func hostOption(host, hostname string) config.HttpClientOption {
+	for _, hostOption := range config.App().HttpClient.Hosts {
+		if hostOption.Host == host || hostOption.Host == hostname {
+			return hostOption.HttpClientOption
+		}
+	}
+	return config.App().HttpClient.HttpClientOption
+}
+
+// MaxIdleConnsPerHost：决定了对于单个Host需要维持的连接池大小。该值应该根据性能测试的结果调整。
+// MaxIdleConns：全局的最大空闲连接，不要比MaxIdleConnsPerHost小，嫌麻烦的话建议不设置或者设置为0 --- 即不限制。
+// MaxConnsPerHost：对于单个Host允许的最大连接数，包含IdleConns，所以一般大于等于MaxIdleConnsPerHost。设置为等于MaxIdleConnsPerHost，也就是尽可能复用连接池中的连接。另外设置过小，可能会导致并发下降，它的默认值是不做限制。
+func newTransport(option config.HttpClientOption) *http.Transport {
+	return &http.Transport{
+		//Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   option.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       option.MaxConnsPerHost,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// httpClient 返回发起请求使用的 http.Client
+func (hc *hostClient) httpClient() *http.Client {
+	if _Client != nil {
+		// 单元测试里要把Client换掉, 让gock这类工具能拦截对外部API的请求
+		return _Client
+	}
+	return hc.client
+}
+
+// SetUTHttpClient 让单元测试能把httpClient覆盖成具有Mock拦截设置的HttpClient
+func SetUTHttpClient(client *http.Client) {
+	_Client = client
+}
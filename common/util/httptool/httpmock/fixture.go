@@ -0,0 +1,106 @@
+package httpmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LoadStubs 从 golden 文件中读取桩
+func LoadStubs(path string) ([]Stub, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stubs []Stub
+	if err = json.Unmarshal(content, &stubs); err != nil {
+		return nil, err
+	}
+	return stubs, nil
+}
+
+// Fixture 根据环境变量 HTTPMOCK_UPDATE 返回回放或者录制 golden 文件的 Transport
+// 回放模式下 save 什么也不做; 录制模式下 save 把录制的请求和响应写入 golden 文件
+// matchHeaders 是录制时写入匹配规则的请求头, 重新录制后 golden 文件中的匹配规则保持不变
+func Fixture(path string, matchHeaders ...string) (rt http.RoundTripper, save func() error, err error) {
+	if os.Getenv(UpdateEnv) != "" {
+		recorder := NewRecorder(http.DefaultTransport, matchHeaders...)
+		return recorder, func() error { return recorder.Save(path) }, nil
+	}
+	stubs, err := LoadStubs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewTransport(stubs...), func() error { return nil }, nil
+}
+
+// Recorder 把请求转发给真实的 Transport, 同时记录请求和响应
+type Recorder struct {
+	next http.RoundTripper
+	// MatchHeaders 录制时写入匹配规则的请求头, 追踪ID这类每次都变化的请求头不要加进来
+	MatchHeaders []string
+
+	mu    sync.Mutex
+	stubs []Stub
+}
+
+func NewRecorder(next http.RoundTripper, matchHeaders ...string) *Recorder {
+	return &Recorder{next: next, MatchHeaders: matchHeaders}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&http.Request{Body: resp.Body})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = (&Response{Body: string(respBody)}).toHttpResponse(req).Body
+
+	stub := Stub{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Body:   string(reqBody),
+		Response: Response{
+			Status: resp.StatusCode,
+			Body:   string(respBody),
+		},
+	}
+	for _, key := range r.MatchHeaders {
+		if value := req.Header.Get(key); value != "" {
+			if stub.Headers == nil {
+				stub.Headers = make(map[string]string)
+			}
+			stub.Headers[key] = value
+		}
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		stub.Response.Headers = map[string]string{"Content-Type": contentType}
+	}
+	r.mu.Lock()
+	r.stubs = append(r.stubs, stub)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Save 把录制的内容写入 golden 文件
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	content, err := json.MarshalIndent(r.stubs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
@@ -0,0 +1,115 @@
+package httpmock
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecorderSaveAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(http.DefaultTransport, "User-Agent")
+	client := &http.Client{Transport: recorder}
+	req := newRequest(t, http.MethodPost, server.URL+"/orders?b=2&a=1", `{"id":1}`, map[string]string{
+		"User-Agent": "go-mall-test",
+		"X-Trace-Id": "not recorded",
+	})
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"echo":{"id":1}}` {
+		t.Fatalf("recorder should pass the real response through, got %s", body)
+	}
+
+	path := filepath.Join(t.TempDir(), "nested", "orders.json")
+	if err = recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := LoadStubs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Stub{{
+		Method:  http.MethodPost,
+		URL:     server.URL + "/orders?b=2&a=1",
+		Headers: map[string]string{"User-Agent": "go-mall-test"},
+		Body:    `{"id":1}`,
+		Response: Response{
+			Status:  http.StatusCreated,
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    `{"echo":{"id":1}}`,
+		},
+	}}
+	if !reflect.DeepEqual(stubs, want) {
+		t.Fatalf("saved stubs = %+v, want %+v", stubs, want)
+	}
+
+	// 关掉服务后用 golden 文件回放
+	server.Close()
+	replay := &http.Client{Transport: NewTransport(stubs...)}
+	resp, err = replay.Do(newRequest(t, http.MethodPost, want[0].URL, `{"id":1}`, map[string]string{"User-Agent": "go-mall-test"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated || string(body) != `{"echo":{"id":1}}` {
+		t.Errorf("replayed response = %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestFixtureModes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	recorder := NewRecorder(NewTransport(Stub{URL: "https://api.example.com/ping", Response: Response{Body: "pong"}}))
+	if _, err := (&http.Client{Transport: recorder}).Get("https://api.example.com/ping"); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(UpdateEnv, "")
+	rt, save, err := Fixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rt.(*Transport); !ok {
+		t.Errorf("Fixture should replay without %s, got %T", UpdateEnv, rt)
+	}
+	if err = save(); err != nil {
+		t.Errorf("save in replay mode should do nothing, got %v", err)
+	}
+
+	t.Setenv(UpdateEnv, "1")
+	rt, _, err = Fixture(filepath.Join(t.TempDir(), "missing.json"), "User-Agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording, ok := rt.(*Recorder)
+	if !ok {
+		t.Fatalf("Fixture should record with %s set, got %T", UpdateEnv, rt)
+	}
+	if !reflect.DeepEqual(recording.MatchHeaders, []string{"User-Agent"}) {
+		t.Errorf("recorder should keep the header matchers, got %v", recording.MatchHeaders)
+	}
+
+	if _, _, err = Fixture(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("record mode should not read the golden file, got %v", err)
+	}
+	t.Setenv(UpdateEnv, "")
+	if _, _, err = Fixture(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("replay mode should fail when the golden file is missing")
+	}
+}
@@ -0,0 +1,180 @@
+// Package httpmock 为基于 httptool 的对接库(library/)提供不依赖外部网络的单元测试工具
+//
+// 桩模式: 按请求方法、URL、请求头、请求体匹配预先设置的响应
+//
+//	transport := httpmock.NewTransport()
+//	transport.AddStub(httpmock.Stub{
+//		Method:   http.MethodGet,
+//		URL:      "https://ipwho.is",
+//		Response: httpmock.Response{Status: 200, Body: `{"ip":"8.8.8.8"}`},
+//	})
+//	defer httpmock.Install(transport)()
+//
+// 录制回放模式: 用 golden 文件保存真实的请求和响应, 测试时离线回放
+// 设置环境变量 HTTPMOCK_UPDATE=1 运行测试时请求真实接口, 并把结果写入 golden 文件
+//
+//	transport, save, err := httpmock.Fixture("testdata/whois/get_host_ip_detail.json", "User-Agent")
+//	defer httpmock.Install(transport)()
+//	... 调用对接库 ...
+//	err = save() // 回放模式下什么也不做
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-study-lab/go-mall/common/util/httptool"
+)
+
+// UpdateEnv 设置这个环境变量后 Fixture 会请求真实接口并更新 golden 文件
+const UpdateEnv = "HTTPMOCK_UPDATE"
+
+// ErrNoStubMatched 请求没有匹配到任何桩, 对接库发出了预期之外的请求
+var ErrNoStubMatched = errors.New("httpmock: no stub matched")
+
+// Stub 请求匹配规则和对应的响应, 也是 golden 文件中每一项的格式
+type Stub struct {
+	Method string `json:"method"`
+	// URL 完整的请求地址, 查询参数的顺序不影响匹配
+	URL string `json:"url"`
+	// Headers 请求中必须包含的请求头, 没有列出的请求头不参与匹配
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body 请求体, 为空时不参与匹配, JSON格式的请求体按语义比较
+	Body     string   `json:"body,omitempty"`
+	Response Response `json:"response"`
+}
+
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+// Transport 按桩返回响应的 http.RoundTripper, 匹配时按添加的顺序取第一个匹配的桩
+type Transport struct {
+	mu       sync.Mutex
+	stubs    []Stub
+	requests []*http.Request
+}
+
+func NewTransport(stubs ...Stub) *Transport {
+	return &Transport{stubs: stubs}
+}
+
+// AddStub 添加桩
+func (t *Transport) AddStub(stub Stub) *Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stubs = append(t.stubs, stub)
+	return t
+}
+
+// Requests 返回收到的所有请求, 用于断言对接库发出的请求
+func (t *Transport) Requests() []*http.Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*http.Request(nil), t.requests...)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, req)
+	for _, stub := range t.stubs {
+		if stub.matches(req, body) {
+			return stub.Response.toHttpResponse(req), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoStubMatched, req.Method, req.URL.String())
+}
+
+func (stub *Stub) matches(req *http.Request, body []byte) bool {
+	if stub.Method != "" && !strings.EqualFold(stub.Method, req.Method) {
+		return false
+	}
+	if !sameURL(stub.URL, req.URL) {
+		return false
+	}
+	for key, value := range stub.Headers {
+		if req.Header.Get(key) != value {
+			return false
+		}
+	}
+	return stub.Body == "" || sameBody([]byte(stub.Body), body)
+}
+
+func sameURL(expected string, actual *url.URL) bool {
+	u, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != actual.Scheme || u.Host != actual.Host ||
+		strings.TrimSuffix(u.Path, "/") != strings.TrimSuffix(actual.Path, "/") {
+		return false
+	}
+	return reflect.DeepEqual(u.Query(), actual.Query())
+}
+
+func sameBody(expected, actual []byte) bool {
+	var expectedJSON, actualJSON interface{}
+	if json.Unmarshal(expected, &expectedJSON) == nil && json.Unmarshal(actual, &actualJSON) == nil {
+		return reflect.DeepEqual(expectedJSON, actualJSON)
+	}
+	return bytes.Equal(expected, actual)
+}
+
+func (resp *Response) toHttpResponse(req *http.Request) *http.Response {
+	header := make(http.Header, len(resp.Headers))
+	for key, value := range resp.Headers {
+		header.Set(key, value)
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+// readBody 读出请求体用于匹配或录制, 再放回请求中
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// Install 让 httptool 的所有请求都经过 rt, 返回恢复原状的函数
+func Install(rt http.RoundTripper) (restore func()) {
+	httptool.SetUTHttpClient(&http.Client{Transport: rt})
+	return func() {
+		httptool.SetUTHttpClient(nil)
+	}
+}
@@ -0,0 +1,132 @@
+package httpmock
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newRequest(t *testing.T, method, url, body string, headers map[string]string) *http.Request {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return req
+}
+
+func TestStubMatches(t *testing.T) {
+	stub := Stub{
+		Method:  http.MethodPost,
+		URL:     "https://api.example.com/orders?a=1&b=2",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Body:    `{"user_id":1,"goods":[1,2]}`,
+	}
+	auth := map[string]string{"Authorization": "Bearer token", "X-Trace-Id": "123"}
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		headers map[string]string
+		want    bool
+	}{
+		{"exact", http.MethodPost, "https://api.example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, true},
+		{"method case insensitive", "post", "https://api.example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, true},
+		{"query order ignored", http.MethodPost, "https://api.example.com/orders?b=2&a=1", `{"user_id":1,"goods":[1,2]}`, auth, true},
+		{"trailing slash ignored", http.MethodPost, "https://api.example.com/orders/?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, true},
+		{"json body compared semantically", http.MethodPost, "https://api.example.com/orders?a=1&b=2", `{ "goods": [1, 2], "user_id": 1 }`, auth, true},
+		{"method mismatch", http.MethodPut, "https://api.example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, false},
+		{"scheme mismatch", http.MethodPost, "http://api.example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, false},
+		{"host mismatch", http.MethodPost, "https://example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, false},
+		{"path mismatch", http.MethodPost, "https://api.example.com/order?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, auth, false},
+		{"query mismatch", http.MethodPost, "https://api.example.com/orders?a=1&b=3", `{"user_id":1,"goods":[1,2]}`, auth, false},
+		{"missing query", http.MethodPost, "https://api.example.com/orders?a=1", `{"user_id":1,"goods":[1,2]}`, auth, false},
+		{"header missing", http.MethodPost, "https://api.example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, nil, false},
+		{"header mismatch", http.MethodPost, "https://api.example.com/orders?a=1&b=2", `{"user_id":1,"goods":[1,2]}`, map[string]string{"Authorization": "Bearer other"}, false},
+		{"json body mismatch", http.MethodPost, "https://api.example.com/orders?a=1&b=2", `{"user_id":2,"goods":[1,2]}`, auth, false},
+		{"non json body mismatch", http.MethodPost, "https://api.example.com/orders?a=1&b=2", `user_id=1`, auth, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(t, tt.method, tt.url, tt.body, tt.headers)
+			body, err := readBody(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stub.matches(req, body); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStubMatchesOptionalFields(t *testing.T) {
+	// 没有设置 Method 和 Body 的桩匹配任意请求方法和请求体
+	stub := Stub{URL: "https://api.example.com/ping"}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req := newRequest(t, method, "https://api.example.com/ping", "anything", nil)
+		body, _ := readBody(req)
+		if !stub.matches(req, body) {
+			t.Errorf("%s request should match a stub without method and body", method)
+		}
+	}
+	// 非JSON的请求体按字节比较
+	stub = Stub{URL: "https://api.example.com/form", Body: "a=1&b=2"}
+	req := newRequest(t, http.MethodPost, "https://api.example.com/form", "a=1&b=2", nil)
+	body, _ := readBody(req)
+	if !stub.matches(req, body) {
+		t.Error("identical form body should match")
+	}
+}
+
+func TestTransportRoundTrip(t *testing.T) {
+	transport := NewTransport(Stub{
+		Method:   http.MethodGet,
+		URL:      "https://api.example.com/users/1",
+		Response: Response{Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"id":1}`},
+	})
+	transport.AddStub(Stub{
+		Method:   http.MethodGet,
+		URL:      "https://api.example.com/users/1",
+		Response: Response{Status: http.StatusNotFound, Body: "shadowed"},
+	})
+
+	resp, err := transport.RoundTrip(newRequest(t, http.MethodGet, "https://api.example.com/users/1", "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != `{"id":1}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("first matched stub should win, got %d %s %v", resp.StatusCode, body, resp.Header)
+	}
+
+	_, err = transport.RoundTrip(newRequest(t, http.MethodGet, "https://api.example.com/users/2", "", nil))
+	if !errors.Is(err, ErrNoStubMatched) {
+		t.Errorf("unmatched request should return ErrNoStubMatched, got %v", err)
+	}
+	if n := len(transport.Requests()); n != 2 {
+		t.Errorf("Requests() should record every request, got %d", n)
+	}
+}
+
+func TestTransportKeepsRequestBody(t *testing.T) {
+	transport := NewTransport(Stub{URL: "https://api.example.com/echo", Body: `{"a":1}`})
+	req := newRequest(t, http.MethodPost, "https://api.example.com/echo", `{"a":1}`, nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(transport.Requests()[0].Body)
+	if string(body) != `{"a":1}` {
+		t.Errorf("request body should still be readable after matching, got %q", body)
+	}
+}
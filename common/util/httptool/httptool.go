@@ -1,25 +1,20 @@
 package httptool
 
 import (
-	"bytes"
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net"
+	"io"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/go-study-lab/go-mall/common/errcode"
 	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/util"
-)
-
-var (
-	_Client *http.Client
-	once    sync.Once
+	"github.com/go-study-lab/go-mall/config"
 )
 
 const (
@@ -38,45 +33,14 @@ func formatLogContent(content []byte, headers map[string]string) string {
 	if len(content) > maxLogContentSize {
 		return "Data too long, skip logging"
 	}
-	return string(content)
-}
-
-func getHttpClient() *http.Client {
-	if _Client != nil {
-		// 因为Unit test里要把Client换掉, 所以虽然用了once.Do 但是还是先判断一下_Client有没有实例化
-		// 不然在单测里, Mock API时, gock没办法拦截对外部API的请求
-		return _Client
-	}
-	once.Do(func() {
-		// MaxIdleConnsPerHost：决定了对于单个Host需要维持的连接池大小。该值应该根据性能测试的结果调整。
-		// MaxIdleConns：全局的最大空闲连接，不要比MaxIdleConnsPerHost小，嫌麻烦的话建议不设置或者设置为0 --- 即不限制。
-		// MaxConnsPerHost：对于单个Host允许的最大连接数，包含IdleConns，所以一般大于等于MaxIdleConnsPerHost。设置为等于MaxIdleConnsPerHost，也就是尽可能复用连接池中的连接。另外设置过小，可能会导致并发下降，它的默认值是不做限制。
-		tr := &http.Transport{
-			//Proxy: http.ProxyFromEnvironment,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			MaxIdleConnsPerHost:   50,
-			MaxConnsPerHost:       50,
-			ForceAttemptHTTP2:     true,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		}
-		_Client = &http.Client{Transport: tr}
-	})
-	return _Client
-}
-
-// SetUTHttpClient 让单元测试能把httpClient覆盖成具有Mock拦截设置的HttpClient
-func SetUTHttpClient(client *http.Client) {
-	_Client = client
+	return logger.RedactString(string(content))
 }
 
+// Request 发起HTTP请求, 2xx 以外的状态码会返回错误
+// 超时、重试、熔断使用配置 app.http_client 中请求的Host对应的配置, 可以通过 WithTimeout, WithRetry 覆盖
+// 只有幂等的请求方法会重试, 通过 WithJSONResult 可以把响应体直接解析到指定的结构体中
+// 通过 WithResponseWriter 把响应体流式写出时, 返回的 respBody 为空
 func Request(method string, url string, options ...Option) (httpStatusCode int, respBody []byte, err error) {
-	start := time.Now()
 	reqOpts := defaultRequestOptions() // 默认的请求选项
 	for _, opt := range options {      // 在reqOpts上应用通过options设置的选项
 		err = opt.apply(reqOpts)
@@ -89,58 +53,155 @@ func Request(method string, url string, options ...Option) (httpStatusCode int,
 			logger.Error(reqOpts.ctx, "HTTP_REQUEST_ERROR_LOG",
 				"method", method,
 				"url", url,
-				"body", formatLogContent(reqOpts.data, reqOpts.headers),
-				"reply", formatLogContent(respBody, reqOpts.headers),
+				"body", reqOpts.logBody(),
+				"reply", reqOpts.logReply(respBody),
 				"status", httpStatusCode,
 				"err", err)
 		}
 	}()
-	// 创建请求对象
-	req, err := http.NewRequest(method, url, bytes.NewReader(reqOpts.data))
+	parsedUrl, err := neturl.Parse(url)
 	if err != nil {
 		return
 	}
-	reqOpts.ctx, _ = context.WithTimeout(reqOpts.ctx, reqOpts.timeout) // 给 Request 设置Timeout
-	req = req.WithContext(reqOpts.ctx)
-	defer req.Body.Close()
-
+	if len(reqOpts.query) > 0 {
+		// 追加查询参数, 与URL中已有的参数合并
+		query := parsedUrl.Query()
+		for key, value := range reqOpts.query {
+			query.Set(key, value)
+		}
+		parsedUrl.RawQuery = query.Encode()
+		url = parsedUrl.String()
+	}
+	hc := getHostClient(parsedUrl.Host, parsedUrl.Hostname())
+	if reqOpts.timeout <= 0 {
+		reqOpts.timeout = hc.option.Timeout
+	}
+	maxRetries := *hc.option.MaxRetries
+	if reqOpts.maxRetries >= 0 {
+		maxRetries = reqOpts.maxRetries
+	}
+	if !isIdempotent(method) || !reqOpts.replayable() {
+		// 非幂等的请求重试可能造成重复操作, 流式的请求体读过一次之后没法再发送
+		maxRetries = 0
+	}
 	// 在Header中添加追踪信息 把内部服务串起来
 	traceId, spanId, _ := util.GetTraceInfoFromCtx(reqOpts.ctx)
 	reqOpts.headers["traceid"] = traceId
 	reqOpts.headers["spanid"] = spanId
-	if len(reqOpts.headers) != 0 { // 设置请求头
-		for key, value := range reqOpts.headers {
-			req.Header.Add(key, value)
+
+	for attempt := 0; ; attempt++ {
+		if !hc.breaker.allow() {
+			err = errcode.Wrap("request api error", ErrCircuitOpen)
+			return
+		}
+		var retryable bool
+		httpStatusCode, respBody, retryable, err = doRequest(hc, method, url, reqOpts)
+		switch {
+		case reqOpts.ctx.Err() != nil:
+			// 调用方取消了请求, 不算对方服务的失败
+			hc.breaker.abandon()
+		default:
+			// 网络错误和 5xx 计入熔断统计, 4xx 是请求本身的问题
+			hc.breaker.record(err == nil || (httpStatusCode > 0 && httpStatusCode < http.StatusInternalServerError))
+		}
+		if err == nil || !retryable || attempt >= maxRetries || reqOpts.ctx.Err() != nil {
+			break
 		}
+		backoff := retryBackoff(hc.option, attempt)
+		logger.Warn(reqOpts.ctx, "HTTP_REQUEST_RETRY", "method", method, "url", url, "status", httpStatusCode,
+			"err", err, "attempt", attempt+1, "backoff", backoff.String())
+		select {
+		case <-reqOpts.ctx.Done():
+			err = reqOpts.ctx.Err()
+			return
+		case <-time.After(backoff):
+		}
+	}
+	if err != nil {
+		return
+	}
+	if reqOpts.result != nil && reqOpts.respWriter == nil {
+		if err = json.Unmarshal(respBody, reqOpts.result); err != nil {
+			err = errcode.Wrap("decode api response error", err)
+		}
+	}
+	return
+}
+
+// doRequest 发起一次请求, retryable 表示失败后是否可以重试
+func doRequest(hc *hostClient, method, url string, reqOpts *requestOption) (httpStatusCode int, respBody []byte, retryable bool, err error) {
+	start := time.Now()
+	// 创建请求对象, 每次重试都重新创建请求体
+	ctx, cancel := context.WithTimeout(reqOpts.ctx, reqOpts.timeout) // 给 Request 设置Timeout, 下载大文件时需要设置得足够长
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, reqOpts.requestBody())
+	if err != nil {
+		return
+	}
+	for key, value := range reqOpts.headers { // 设置请求头
+		req.Header.Add(key, value)
 	}
 	// 发起请求
-	client := getHttpClient()
-	resp, err := client.Do(req)
+	resp, err := hc.httpClient().Do(req)
 	if err != nil {
+		retryable = true
 		return
 	}
 	defer resp.Body.Close()
+	httpStatusCode = resp.StatusCode
+	if reqOpts.respWriter != nil && httpStatusCode >= 200 && httpStatusCode < 300 {
+		// 流式写出响应体, 不在内存中保留; 已经写出部分数据后失败不能重试
+		_, err = io.Copy(reqOpts.respWriter, resp.Body)
+		if err != nil {
+			logger.Error(reqOpts.ctx, "HTTP_RESPONSE_STREAM_ERROR", "method", method, "url", url, "err", err)
+			return
+		}
+	} else {
+		// 先读取响应体, 再进行状态码检查, 避免非2xx状态码时, 日志中响应体记录不到
+		respBody, err = io.ReadAll(resp.Body)
+	}
 	// 记录请求日志
 	dur := time.Since(start).Milliseconds()
-	defer func() {
-		if dur >= 3000 { // 超过 3s 返回, 记一条 Warn 日志
-			logger.Warn(reqOpts.ctx, "HTTP_REQUEST_SLOW_LOG", "method", method, "url", url, "body", formatLogContent(reqOpts.data, reqOpts.headers), "reply", formatLogContent(respBody, reqOpts.headers), "err", err, "dur/ms", dur)
-		} else {
-			logger.Debug(reqOpts.ctx, "HTTP_REQUEST_DEBUG_LOG", "method", method, "url", url, "body", formatLogContent(reqOpts.data, reqOpts.headers), "reply", formatLogContent(respBody, reqOpts.headers), "err", err, "dur/ms", dur)
-		}
-	}()
+	if dur >= 3000 { // 超过 3s 返回, 记一条 Warn 日志
+		logger.Warn(reqOpts.ctx, "HTTP_REQUEST_SLOW_LOG", "method", method, "url", url, "body", reqOpts.logBody(), "reply", reqOpts.logReply(respBody), "err", err, "dur/ms", dur)
+	} else {
+		logger.Debug(reqOpts.ctx, "HTTP_REQUEST_DEBUG_LOG", "method", method, "url", url, "body", reqOpts.logBody(), "reply", reqOpts.logReply(respBody), "err", err, "dur/ms", dur)
+	}
+	if err != nil {
+		retryable = true
+		return
+	}
+	if httpStatusCode < 200 || httpStatusCode >= 300 {
+		// 返回非 2xx 时Go的 http 库不回返回error, 这里处理成error 调用方好判断
+		err = errcode.Wrap("request api error", fmt.Errorf("non 2xx response, response code: %d", httpStatusCode))
+		retryable = isRetryableStatus(httpStatusCode)
+	}
+	return
+}
 
-	// 先读取响应体, 再进行状态码检查, 避免非HTTP 200 状态码时, 日志中响应体记录不到
-	respBody, _ = ioutil.ReadAll(resp.Body)
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
 
-	httpStatusCode = resp.StatusCode
-	if httpStatusCode != http.StatusOK {
-		// 返回非 200 时Go的 http 库不回返回error, 这里处理成error 调用方好判断
-		err = errcode.Wrap("request api error", errors.New(fmt.Sprintf("non 200 response, response code: %d", httpStatusCode)))
-		return
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
 	}
+	return false
+}
 
-	return
+// retryBackoff 指数退避, 加上一半以内的随机抖动避免多个请求同时重试
+func retryBackoff(option config.HttpClientOption, attempt int) time.Duration {
+	backoff := option.RetryBackoff << attempt
+	if backoff <= 0 || backoff > option.MaxRetryBackoff {
+		backoff = option.MaxRetryBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
 }
 
 func Get(ctx context.Context, url string, options ...Option) (httpStatusCode int, respBody []byte, err error) {
@@ -150,30 +211,65 @@ func Get(ctx context.Context, url string, options ...Option) (httpStatusCode int
 
 // Post 发起POST请求
 func Post(ctx context.Context, url string, data []byte, options ...Option) (httpStatusCode int, respBody []byte, err error) {
+	return requestWithJSONBody(ctx, http.MethodPost, url, data, options...)
+}
+
+// Put 发起PUT请求, 默认请求体格式同 Post
+func Put(ctx context.Context, url string, data []byte, options ...Option) (httpStatusCode int, respBody []byte, err error) {
+	return requestWithJSONBody(ctx, http.MethodPut, url, data, options...)
+}
+
+// Patch 发起PATCH请求, 默认请求体格式同 Post
+func Patch(ctx context.Context, url string, data []byte, options ...Option) (httpStatusCode int, respBody []byte, err error) {
+	return requestWithJSONBody(ctx, http.MethodPatch, url, data, options...)
+}
+
+// Delete 发起DELETE请求
+func Delete(ctx context.Context, url string, options ...Option) (httpStatusCode int, respBody []byte, err error) {
+	options = append(options, WithContext(ctx))
+	return Request(http.MethodDelete, url, options...)
+}
+
+// Download 把响应体流式写入 w, 适合下载大文件, 注意用 WithTimeout 设置足够长的超时时间
+func Download(ctx context.Context, url string, w io.Writer, options ...Option) (httpStatusCode int, err error) {
+	options = append(options, WithContext(ctx), WithResponseWriter(w))
+	httpStatusCode, _, err = Request(http.MethodGet, url, options...)
+	return
+}
+
+func requestWithJSONBody(ctx context.Context, method, url string, data []byte, options ...Option) (httpStatusCode int, respBody []byte, err error) {
 	// 默认自带Header Content-Type: application/json 可通过 传递 WithHeaders 增加或者覆盖Header信息
+	// 通过 WithForm, WithMultipart, WithBodyReader 设置请求体时会替换掉 data
 	defaultHeader := map[string]string{"Content-Type": "application/json"}
 	var newOptions []Option
 	newOptions = append(newOptions, WithHeaders(defaultHeader), WithData(data), WithContext(ctx))
 	newOptions = append(newOptions, options...)
 
-	httpStatusCode, respBody, err = Request("POST", url, newOptions...)
+	httpStatusCode, respBody, err = Request(method, url, newOptions...)
 	return
 }
 
 // 针对可选的HTTP请求配置项，模仿gRPC使用的Options设计模式实现
 type requestOption struct {
-	ctx     context.Context
-	timeout time.Duration
-	data    []byte
-	headers map[string]string
+	ctx        context.Context
+	timeout    time.Duration // 为0时使用Host的配置
+	maxRetries int           // 小于0时使用Host的配置
+	query      map[string]string
+	data       []byte
+	bodyReader io.Reader      // 流式请求体, 只能发送一次
+	multipart  *multipartBody // multipart/form-data 请求体, 发送时边读文件边写
+	headers    map[string]string
+	result     interface{}
+	respWriter io.Writer
 }
 
 func defaultRequestOptions() *requestOption {
 	return &requestOption{
-		ctx:     context.Background(),
-		timeout: 5 * time.Second,
-		data:    nil,
-		headers: map[string]string{},
+		ctx:        context.Background(),
+		timeout:    0,
+		maxRetries: -1,
+		data:       nil,
+		headers:    map[string]string{},
 	}
 }
 
@@ -215,3 +311,74 @@ func WithData(data []byte) Option {
 		return
 	})
 }
+
+// WithRetry 覆盖配置中的最大重试次数, 只对幂等的请求方法生效
+func WithRetry(maxRetries int) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.maxRetries = maxRetries
+		return
+	})
+}
+
+// WithJSONResult 请求成功时把JSON响应体解析到 result 中, result 需要是指针
+func WithJSONResult(result interface{}) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.result = result
+		return
+	})
+}
+
+// WithQuery 添加URL查询参数, 与URL中已有的同名参数冲突时覆盖
+func WithQuery(params map[string]string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		if opts.query == nil {
+			opts.query = make(map[string]string, len(params))
+		}
+		for k, v := range params {
+			opts.query[k] = v
+		}
+		return
+	})
+}
+
+// WithForm 以 application/x-www-form-urlencoded 格式发送表单
+func WithForm(form map[string]string) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		values := neturl.Values{}
+		for k, v := range form {
+			values.Set(k, v)
+		}
+		opts.data = []byte(values.Encode())
+		opts.bodyReader, opts.multipart = nil, nil
+		opts.headers["Content-Type"] = "application/x-www-form-urlencoded"
+		return
+	})
+}
+
+// WithMultipart 以 multipart/form-data 格式上传文件, fields 是普通的表单字段
+// 文件内容在发送时才从 Reader 中读取, 不会整个读入内存, 请求失败后不会重试
+func WithMultipart(fields map[string]string, files ...MultipartFile) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.multipart = newMultipartBody(fields, files)
+		opts.data, opts.bodyReader = nil, nil
+		opts.headers["Content-Type"] = opts.multipart.contentType()
+		return
+	})
+}
+
+// WithBodyReader 从 Reader 中流式读取请求体, 请求失败后不会重试
+func WithBodyReader(body io.Reader) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.bodyReader = body
+		opts.data, opts.multipart = nil, nil
+		return
+	})
+}
+
+// WithResponseWriter 请求成功时把响应体流式写入 w, 不在内存中保留, 非2xx的响应体仍然会读出来用于记录日志
+func WithResponseWriter(w io.Writer) Option {
+	return optionFunc(func(opts *requestOption) (err error) {
+		opts.respWriter = w
+		return
+	})
+}
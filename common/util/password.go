@@ -1,10 +1,15 @@
 package util
 
 import (
-	"golang.org/x/crypto/bcrypt"
 	"unicode"
+
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// PasswordComplexityRule 密码复杂度的校验规则名, 各语言的错误信息在 i18n 的 validations 中
+const PasswordComplexityRule = "password_complexity"
+
 func BcryptPassword(plainPassword string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(plainPassword), 11) // 第二个参数是cost， 值越大，加密越慢，安全性越高
 	return string(bytes), err
@@ -45,3 +50,8 @@ func PasswordComplexityVerify(s string) bool {
 	}
 	return hasMinLen && hasUpper && hasLower && hasNumber && hasSpecial
 }
+
+// PasswordComplexityFieldError 密码复杂度不满足时返回给客户端的字段错误
+func PasswordComplexityFieldError(field string) errcode.FieldError {
+	return errcode.FieldError{Field: field, Rule: PasswordComplexityRule}
+}
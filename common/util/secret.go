@@ -0,0 +1,72 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 配置文件中敏感配置项的加解密, 加密后的值以 ENC(...) 的形式写在配置文件中
+
+const (
+	// SecretMasterKeyEnv 加解密配置使用的主密钥
+	SecretMasterKeyEnv = "GOMALL_MASTER_KEY"
+	// SecretMasterKeyFileEnv 主密钥也可以放在文件中, 通过这个环境变量指定文件路径
+	SecretMasterKeyFileEnv = "GOMALL_MASTER_KEY_FILE"
+
+	encryptedValuePrefix = "ENC("
+	encryptedValueSuffix = ")"
+)
+
+// LoadSecretMasterKey 从环境变量或密钥文件中读取主密钥, 主密钥经过 sha256 后作为 AES-256 的密钥
+func LoadSecretMasterKey() ([]byte, error) {
+	masterKey := os.Getenv(SecretMasterKeyEnv)
+	if masterKey == "" {
+		if keyFile := os.Getenv(SecretMasterKeyFileEnv); keyFile != "" {
+			content, err := os.ReadFile(keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("read master key file error: %w", err)
+			}
+			masterKey = strings.TrimSpace(string(content))
+		}
+	}
+	if masterKey == "" {
+		return nil, fmt.Errorf("master key is not set, please set %s or %s", SecretMasterKeyEnv, SecretMasterKeyFileEnv)
+	}
+	key := sha256.Sum256([]byte(masterKey))
+	return key[:], nil
+}
+
+// IsEncryptedValue 判断配置值是否是 ENC(...) 形式的密文
+func IsEncryptedValue(value string) bool {
+	return strings.HasPrefix(value, encryptedValuePrefix) && strings.HasSuffix(value, encryptedValueSuffix)
+}
+
+// EncryptSecretValue 加密配置值, 返回 ENC(base64密文)
+func EncryptSecretValue(plainValue string, key []byte) (string, error) {
+	encrypted, err := AesGcmEncrypt([]byte(plainValue), key)
+	if err != nil {
+		return "", err
+	}
+	return encryptedValuePrefix + base64.StdEncoding.EncodeToString(encrypted) + encryptedValueSuffix, nil
+}
+
+// DecryptSecretValue 解密 ENC(...) 形式的配置值
+func DecryptSecretValue(value string, key []byte) (string, error) {
+	if !IsEncryptedValue(value) {
+		return "", errors.New("value is not in ENC(...) format")
+	}
+	encoded := value[len(encryptedValuePrefix) : len(value)-len(encryptedValueSuffix)]
+	encrypted, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	plain, err := AesGcmDecrypt(encrypted, key)
+	if err != nil {
+		return "", errors.New("decrypt failed, the master key is wrong or the value has been tampered with")
+	}
+	return string(plain), nil
+}
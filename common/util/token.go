@@ -1,9 +1,11 @@
 package util
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -52,23 +54,31 @@ func GenSessionId(userId int64) string {
 	return fmt.Sprintf("%d-%d-%s", userId, time.Now().Unix(), RandNumStr(6))
 }
 
-// ParseUserIdFromToken 从Token中反解出userId,
+var errInvalidToken = errors.New("invalid token")
+
+// ParseUserIdFromToken 从Token中反解出userId, Token由客户端传入, 格式不合法时返回错误
 // 后端服务redis不可用也没法立即恢复时可以使用这个方式保持产品最基本功能的使用, 不至于直接白屏
 func ParseUserIdFromToken(accessToken string) (userId int64, err error) {
 	if len(accessToken) != 2*(md5Len+aesLen) {
 		// Token 格式不对
-		return
+		return 0, errInvalidToken
 	}
-	encodeStr := accessToken[md5Len*2:]
-	data, err := hex.DecodeString(encodeStr)
+	data, err := hex.DecodeString(accessToken)
 	if err != nil {
-		return
+		return 0, errInvalidToken
+	}
+	decodeByte, err := AesDecrypt(data[md5Len:], []byte(aesKEY))
+	if err != nil || len(decodeByte) < 8 {
+		return 0, errInvalidToken
+	}
+	// 校验Token开头的MD5部分, 随意构造的Token不能通过
+	md5Byte := md5.Sum(decodeByte)
+	if !bytes.Equal(md5Byte[:md5Len], data[:md5Len]) {
+		return 0, errInvalidToken
 	}
-	decodeByte, _ := AesDecrypt(data, []byte(aesKEY)) //忽略错误
 	uid := binary.BigEndian.Uint64(decodeByte)
 	if uid == 0 {
-		return
+		return 0, errInvalidToken
 	}
-	userId = int64(uid)
-	return
+	return int64(uid), nil
 }
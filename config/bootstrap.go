@@ -5,6 +5,9 @@ import (
 	"embed"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -12,11 +15,26 @@ import (
 
 const CONF_DIR = "config/"
 
+// ENV_CONFIG_FILE 通过这个环境变量指定二进制外部的配置文件路径, 指定后不再读取内嵌的配置文件
+const ENV_CONFIG_FILE = "GOMALL_CONFIG_FILE"
+
+// ENV_PREFIX 环境变量覆盖配置项时使用的前缀, 如 GOMALL_DATABASE_MASTER_DSN 覆盖 database.master.dsn
+const ENV_PREFIX = "GOMALL"
+
 //go:embed *.yaml
 var configs embed.FS
 
+var vp *viper.Viper
+
+// configuration 配置文件的整体结构
+type configuration struct {
+	App      *appConfig      `mapstructure:"app"`
+	Database *databaseConfig `mapstructure:"database"`
+	Redis    *redisConfig    `mapstructure:"redis"`
+}
+
 func init() {
-	err := godotenv.Load()
+	err := godotenv.Load(findDotEnv())
 	if err != nil {
 		log.Println("Error loading .env file")
 	}
@@ -25,19 +43,125 @@ func init() {
 	if !exists {
 		panic("ENV is not set")
 	}
-	vp := viper.New()
-	// 根据环境变量 ENV 决定要读取的应用启动配置
-	configFileStream, err := configs.ReadFile("application." + env + ".yaml")
+	vp = viper.New()
+	vp.SetConfigType("yaml")
+	// 环境变量中设置的值优先于配置文件
+	vp.SetEnvPrefix(ENV_PREFIX)
+	vp.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	bindEnvs(vp, reflect.TypeOf(configuration{}), "")
+
+	configFile := os.Getenv(ENV_CONFIG_FILE)
+	if configFile != "" {
+		// 从外部文件加载配置, 支持热更新
+		vp.SetConfigFile(configFile)
+		err = vp.ReadInConfig()
+	} else {
+		// 根据环境变量 ENV 决定要读取的应用启动配置
+		var configFileStream []byte
+		configFileStream, err = configs.ReadFile("application." + env + ".yaml")
+		if err != nil {
+			panic(err)
+		}
+		err = vp.ReadConfig(bytes.NewBuffer(configFileStream))
+	}
 	if err != nil {
+		// 加载不到配置，阻挡应用的继续启动
 		panic(err)
 	}
-	vp.SetConfigType("yaml")
-	err = vp.ReadConfig(bytes.NewBuffer(configFileStream))
+	conf, err := loadConfiguration(vp)
 	if err != nil {
-		// 加载不到配置，阻挡应用的继续启动
+		// 配置项缺失或者不合法, 阻挡应用的继续启动
 		panic(err)
 	}
-	vp.UnmarshalKey("app", &App)
-	vp.UnmarshalKey("database", &Database)
-	vp.UnmarshalKey("redis", &Redis)
+	currentApp.Store(conf.App)
+	Database, Redis = conf.Database, conf.Redis
+	if configFile != "" {
+		watchConfig()
+	}
+}
+
+// loadConfiguration 把配置解析到结构体中并做校验
+func loadConfiguration(vp *viper.Viper) (*configuration, error) {
+	conf := &configuration{
+		App:      new(appConfig),
+		Database: new(databaseConfig),
+		Redis:    new(redisConfig),
+	}
+	if err := vp.Unmarshal(conf); err != nil {
+		return nil, err
+	}
+	if err := decryptSecrets(conf); err != nil {
+		return nil, err
+	}
+	conf.normalize()
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// findDotEnv 从当前目录向上查找 .env, 最多找到 go.mod 所在的目录
+// go test 在包所在的目录执行, 向上查找才能读到项目根目录的 .env; 找不到时返回当前目录下的 .env
+func findDotEnv() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ".env"
+	}
+	for {
+		if _, err = os.Stat(filepath.Join(dir, ".env")); err == nil {
+			return filepath.Join(dir, ".env")
+		}
+		if _, err = os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return ".env"
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ".env"
+		}
+		dir = parent
+	}
+}
+
+// bindEnvs 按照配置结构体的 mapstructure tag 把每个配置项都绑定到环境变量上
+// viper 的 AutomaticEnv 只对配置文件中出现过的配置项生效, 所以这里显式绑定一遍
+func bindEnvs(vp *viper.Viper, t reflect.Type, prefix string) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if strings.HasSuffix(key, ",squash") {
+			// 嵌入的结构体的配置项与外层结构体在同一层级
+			bindEnvs(vp, fieldType, prefix)
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch fieldType.Kind() {
+		case reflect.Struct:
+			if fieldType.PkgPath() == "time" {
+				vp.BindEnv(key)
+				continue
+			}
+			bindEnvs(vp, fieldType, key)
+		case reflect.Map:
+			// map类型的配置项没有固定的键, 不绑定
+		case reflect.Slice:
+			// 只绑定基础类型的切片, 环境变量中用逗号分隔
+			if fieldType.Elem().Kind() != reflect.Struct {
+				vp.BindEnv(key)
+			}
+		default:
+			vp.BindEnv(key)
+		}
+	}
 }
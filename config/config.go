@@ -1,13 +1,22 @@
 package config
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
 
 var (
-	App      *appConfig
 	Database *databaseConfig
 	Redis    *redisConfig
+	// currentApp 应用配置, 热更新时整体替换, 所以用原子指针保存
+	currentApp atomic.Pointer[appConfig]
 )
 
+// App 返回当前的应用配置, 配置热更新后返回新的配置, 返回的配置是只读的, 不要修改
+func App() *appConfig {
+	return currentApp.Load()
+}
+
 type appConfig struct {
 	Name string `mapstructure:"name"`
 	Env  string `mapstructure:"env"`
@@ -15,16 +24,114 @@ type appConfig struct {
 		FilePath         string `mapstructure:"path"`
 		FileMaxSize      int    `mapstructure:"max_size"`
 		BackUpFileMaxAge int    `mapstructure:"back_up_max_age"`
+		Level            string `mapstructure:"level"`        // 日志级别, 不设置时按环境决定 dev:debug test/prod:info
+		DebugSignSecret  string `mapstructure:"debug_secret"` // 单个请求开启Debug日志时, 验证请求头签名用的密钥
+		// DebugSignMaxTTL 请求头签名的过期时间距离当前时间不能超过这个值, 防止签出长期有效的签名
+		DebugSignMaxTTL time.Duration `mapstructure:"debug_max_ttl"`
+		Redact          struct {
+			Keys    []RedactRule `mapstructure:"keys"`    // 需要脱敏的JSON字段名或者以.分隔的字段路径
+			Headers []string     `mapstructure:"headers"` // 需要脱敏的请求头
+		} `mapstructure:"redact"`
+	}
+	Admin struct {
+		Token string `mapstructure:"token"` // 访问管理接口时需要在请求头 admin-token 中携带的Token
 	}
 	Pagination struct {
-		DefaultSize int `mapstructure:"default_size"`
-		MaxSize     int `mapstructure:"max_size"`
+		DefaultSize  int    `mapstructure:"default_size"`
+		MaxSize      int    `mapstructure:"max_size"`      // 客户端请求的 page_size 超过时按 max_size 处理
+		CursorSecret string `mapstructure:"cursor_secret"` // 游标分页时签名游标的密钥
+	}
+	Idempotency struct {
+		TTL           time.Duration `mapstructure:"ttl"`            // 保存第一次请求响应的时间, 超过后相同的 Idempotency-Key 会作为新请求处理
+		ProcessingTTL time.Duration `mapstructure:"processing_ttl"` // 请求处理中的标记的过期时间, 防止进程崩溃后 Idempotency-Key 一直不可用
+		MaxBodySize   int64         `mapstructure:"max_body_size"`  // 计算请求体摘要时读取的最大字节数, 超过时返回 ErrRequestTooLarge
 	}
+	// Signature APP和合作方客户端的请求签名, 客户端用 app-key 对应的密钥对请求签名, 见 middleware.VerifySignature
+	Signature struct {
+		Enabled   bool          `mapstructure:"enabled"`
+		ClockSkew time.Duration `mapstructure:"clock_skew"` // 允许客户端与服务端时间的误差, nonce 在两倍的误差时间内不能重复使用
+		// MaxBodySize 计算签名时读取的最大请求体字节数, 超过时返回 ErrRequestTooLarge
+		MaxBodySize int64 `mapstructure:"max_body_size"`
+		// Clients 按 app-key 配置客户端, 配置的键会被转成小写, 所以 app-key 只使用小写字母、数字和横线
+		Clients map[string]SignatureClient `mapstructure:"clients"`
+	}
+	RateLimit struct {
+		Enabled bool    `mapstructure:"enabled"`
+		QPS     float64 `mapstructure:"qps"`   // 每秒允许的请求数
+		Burst   int     `mapstructure:"burst"` // 允许的突发请求数
+	} `mapstructure:"rate_limit"`
+	HttpClient struct {
+		HttpClientOption `mapstructure:",squash"` // 默认配置
+		// Hosts 按域名单独配置, 没有配置的项沿用默认配置
+		Hosts []HttpHostOption `mapstructure:"hosts"`
+	} `mapstructure:"http_client"`
+}
+
+// SignatureClient 签名请求的客户端
+type SignatureClient struct {
+	Secret   string `mapstructure:"secret"`   // 签名密钥, 建议用 ENC(...) 加密配置
+	Platform string `mapstructure:"platform"` // 客户端所属的平台, 设置后请求头 platform 必须与之一致, 并作为请求的平台
+}
+
+// HttpHostOption 单个Host的HTTP请求配置, 域名中有"."不能作为配置的键, 所以用列表配置
+type HttpHostOption struct {
+	Host             string `mapstructure:"host"` // 域名或者 域名:端口
+	HttpClientOption `mapstructure:",squash"`
 }
+
+// HttpClientOption 对外发起HTTP请求的配置, 见 httptool.Request
+type HttpClientOption struct {
+	Timeout             time.Duration `mapstructure:"timeout"` // 单次请求的超时时间
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host"`
+	// MaxRetries 幂等请求(GET/HEAD/OPTIONS/PUT/DELETE)在网络错误或者 429/502/503/504 时的最大重试次数
+	// 用指针区分没有配置和配置为0, 单个Host可以配置为0关闭重试
+	MaxRetries *int `mapstructure:"max_retries"`
+	// RetryBackoff 第一次重试前的等待时间, 之后每次翻倍, 不超过 MaxRetryBackoff
+	RetryBackoff    time.Duration `mapstructure:"retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+	// BreakerFailures 对同一个Host连续失败多少次后熔断, 熔断期间请求直接返回错误, 0表示不熔断
+	// 与 MaxRetries 一样用指针区分没有配置和配置为0
+	BreakerFailures *int `mapstructure:"breaker_failures"`
+	// BreakerOpenTimeout 熔断持续的时间, 之后放行一个探测请求, 成功则恢复
+	BreakerOpenTimeout time.Duration `mapstructure:"breaker_open_timeout"`
+}
+
+// RedactRule 日志脱敏规则, Mask 为空时整个值会被替换成 ******
+// Mask 可选值: phone, email, login_name, real_name, token(保留前6位)
+type RedactRule struct {
+	Key  string `mapstructure:"key"`
+	Mask string `mapstructure:"mask"`
+}
+
 type databaseConfig struct {
-	Master DbConnectOption `mapstructure:"master"`
-	Slave  DbConnectOption `mapstructure:"slave"`
+	DatasourceConfig `mapstructure:",squash"` // 默认数据源
+	// Datasources 额外的命名数据源, 让不同的模块可以使用不同的数据库, 通过 dao.NamedDatasource(name) 访问
+	Datasources map[string]DatasourceConfig `mapstructure:"datasources"`
+	// HealthCheckInterval 从库健康检查的间隔
+	HealthCheckInterval time.Duration `mapstructure:"health_check_interval"`
+	// MaxReplicationLag 从库复制延迟超过这个值时暂停从这个从库读取
+	// 查不到复制延迟时(比如MySQL账号缺少 REPLICATION CLIENT 权限)只检查连通性, 并记录 DB_REPLICA_LAG_UNKNOWN 日志
+	MaxReplicationLag time.Duration `mapstructure:"max_replication_lag"`
+	// ReadYourWritesWindow 发生写操作后, 在这个时间窗口内同一请求/用户的读操作都走主库
+	ReadYourWritesWindow time.Duration `mapstructure:"read_your_writes_window"`
+	// AutoMigrate 开发环境启动时按 GORM Model 自动建表、补字段, 只在 dev 环境生效, 其他环境请使用 migrate 命令
+	AutoMigrate bool `mapstructure:"auto_migrate"`
 }
+
+// DatasourceConfig 数据源配置, 未配置从库时读写都使用 Master
+type DatasourceConfig struct {
+	Type     string          `mapstructure:"type"` // 数据库类型 mysql, postgres, sqlite
+	Master   DbConnectOption `mapstructure:"master"`
+	Slave    DbConnectOption `mapstructure:"slave"`    // 只有一个从库时的简化配置, 与 Replicas 二选一
+	Replicas []ReplicaOption `mapstructure:"replicas"` // 多个从库, 按权重分配读请求
+}
+
+type ReplicaOption struct {
+	DbConnectOption `mapstructure:",squash"`
+	Weight          int `mapstructure:"weight"`
+}
+
 type DbConnectOption struct {
 	Type        string        `mapstructure:"type"`
 	DSN         string        `mapstructure:"dsn"`
@@ -34,8 +141,25 @@ type DbConnectOption struct {
 }
 
 type redisConfig struct {
-	Addr     string `mapstructure:"addr"`
-	Password string `mapstructure:"password"`
-	PoolSize int    `mapstructure:"pool_size"`
-	DB       int    `mapstructure:"db"`
+	// Mode 部署模式 standalone(默认), sentinel, cluster
+	Mode string `mapstructure:"mode"`
+	// Addr 单机模式的地址, 与 Addrs 二选一
+	Addr string `mapstructure:"addr"`
+	// Addrs sentinel 模式下是哨兵节点地址, cluster 模式下是集群节点地址
+	Addrs []string `mapstructure:"addrs"`
+	// MasterName sentinel 模式下监控的主节点名称
+	MasterName       string `mapstructure:"master_name"`
+	Password         string `mapstructure:"password"`
+	SentinelPassword string `mapstructure:"sentinel_password"` // 哨兵节点的密码, 与数据节点不同时配置
+	PoolSize         int    `mapstructure:"pool_size"`
+	DB               int    `mapstructure:"db"` // cluster 模式只支持0号库
+	// KeyPrefix 所有缓存键的前缀, 多个项目共用一个Redis时用来区分, 默认 GOMALL
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// Cache 旁路缓存的配置, 见 cache.Get
+	Cache struct {
+		LocalSize   int           `mapstructure:"local_size"`   // 进程内LRU缓存的最大条目数, 0表示不使用本地缓存
+		LocalTTL    time.Duration `mapstructure:"local_ttl"`    // 本地缓存的过期时间, 也是其他实例更新数据后本地缓存最长的不一致时间
+		NegativeTTL time.Duration `mapstructure:"negative_ttl"` // 查不到的数据缓存空值的时间, 防止缓存穿透
+		TTLJitter   float64       `mapstructure:"ttl_jitter"`   // 过期时间随机增加的比例, 避免大量缓存同时过期
+	} `mapstructure:"cache"`
 }
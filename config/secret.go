@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-study-lab/go-mall/common/util"
+)
+
+// decryptSecrets 把解析后的配置中 ENC(...) 形式的加密值解密成明文
+// 在结构体上解密而不是写回 viper, 写回 viper 的值优先级高于配置文件, 热更新时就无法再修改这些配置
+// 只有配置中存在加密值时才要求设置主密钥, 主密钥的设置方式见 util.LoadSecretMasterKey
+func decryptSecrets(conf *configuration) error {
+	d := &secretDecrypter{}
+	return d.decrypt(reflect.ValueOf(conf).Elem(), "")
+}
+
+type secretDecrypter struct {
+	key []byte
+}
+
+// decrypt 递归解密结构体、指针、切片和 map 中的字符串, path 是配置项的路径, 用于错误提示
+func (d *secretDecrypter) decrypt(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return d.decrypt(v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			fieldPath := path
+			if name != "" {
+				fieldPath = joinConfigKey(path, name)
+			} else if !field.Anonymous {
+				fieldPath = joinConfigKey(path, strings.ToLower(field.Name))
+			}
+			if err := d.decrypt(v.Field(i), fieldPath); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := d.decrypt(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map 中的值不能寻址, 复制出来解密后再写回
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			if err := d.decrypt(elem, joinConfigKey(path, fmt.Sprint(iter.Key().Interface()))); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.String:
+		if !util.IsEncryptedValue(v.String()) {
+			return nil
+		}
+		if d.key == nil {
+			key, err := util.LoadSecretMasterKey()
+			if err != nil {
+				return fmt.Errorf("config %s is encrypted: %w", path, err)
+			}
+			d.key = key
+		}
+		plain, err := util.DecryptSecretValue(v.String(), d.key)
+		if err != nil {
+			return fmt.Errorf("decrypt config %s error: %w", path, err)
+		}
+		v.SetString(plain)
+	}
+	return nil
+}
+
+func joinConfigKey(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+const secretUsage = `usage: go-mall secret <command> [value]
+
+commands:
+  encrypt [value]  encrypt a config value, prints ENC(...)
+  decrypt [value]  decrypt an ENC(...) config value
+
+the master key is read from GOMALL_MASTER_KEY or the file set in GOMALL_MASTER_KEY_FILE.
+value is read from stdin when omitted, so plain passwords are not left in the shell history.
+`
+
+// RunSecretCommand 执行 secret 子命令, 加解密配置文件中的敏感配置项, args 是 secret 之后的参数
+func RunSecretCommand(args []string, in io.Reader, out io.Writer) error {
+	if len(args) == 0 || (args[0] != "encrypt" && args[0] != "decrypt") {
+		fmt.Fprint(out, secretUsage)
+		return errors.New("missing or unknown secret command")
+	}
+	key, err := util.LoadSecretMasterKey()
+	if err != nil {
+		return err
+	}
+	var value string
+	if len(args) > 1 {
+		value = args[1]
+	} else {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("read value from stdin error: %w", err)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	var result string
+	if args[0] == "encrypt" {
+		result, err = util.EncryptSecretValue(value, key)
+	} else {
+		result, err = util.DecryptSecretValue(value, key)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, result)
+	return nil
+}
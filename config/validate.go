@@ -0,0 +1,269 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// validate 启动时校验配置, 把所有缺失或不合法的配置项一次性列出来, 方便排查
+func (conf *configuration) validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	app := conf.App
+	if app.Name == "" {
+		addProblem("app.name is required")
+	}
+	switch app.Env {
+	case "dev", "test", "prod":
+	default:
+		addProblem("app.env must be one of dev, test, prod, got %q", app.Env)
+	}
+	if app.Log.FilePath == "" {
+		addProblem("app.log.path is required")
+	}
+	if app.Log.Level != "" && !isValidLogLevel(app.Log.Level) {
+		addProblem("app.log.level must be one of debug, info, warn, error, got %q", app.Log.Level)
+	}
+	if app.Pagination.CursorSecret == "" {
+		addProblem("app.pagination.cursor_secret is required")
+	}
+	if app.Signature.Enabled && len(app.Signature.Clients) == 0 {
+		addProblem("app.signature.clients is required when signature is enabled")
+	}
+	for appKey, client := range app.Signature.Clients {
+		if client.Secret == "" {
+			addProblem("app.signature.clients.%s.secret is required", appKey)
+		}
+	}
+	problems = append(problems, app.HttpClient.HttpClientOption.validate("app.http_client")...)
+	for i, hostOption := range app.HttpClient.Hosts {
+		if hostOption.Host == "" {
+			addProblem("app.http_client.hosts[%d].host is required", i)
+		}
+		problems = append(problems, hostOption.validate(fmt.Sprintf("app.http_client.hosts[%d]", i))...)
+	}
+	problems = append(problems, app.validateHotReloadable()...)
+
+	db := conf.Database
+	problems = append(problems, db.DatasourceConfig.validate("database")...)
+	for name, ds := range db.Datasources {
+		problems = append(problems, ds.validate("database.datasources."+name)...)
+	}
+
+	problems = append(problems, conf.Redis.validate()...)
+	if conf.Redis.Cache.LocalSize < 0 {
+		addProblem("redis.cache.local_size must not be negative")
+	}
+	if conf.Redis.Cache.TTLJitter < 0 || conf.Redis.Cache.TTLJitter > 1 {
+		addProblem("redis.cache.ttl_jitter must be between 0 and 1, got %v", conf.Redis.Cache.TTLJitter)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// validateHotReloadable 校验可以热更新的配置项, 热更新时未通过校验的配置不会生效
+func (app *appConfig) validateHotReloadable() (problems []string) {
+	if app.Pagination.DefaultSize <= 0 {
+		problems = append(problems, "app.pagination.default_size must be greater than 0")
+	}
+	if app.Pagination.MaxSize < app.Pagination.DefaultSize {
+		problems = append(problems, "app.pagination.max_size must not be less than app.pagination.default_size")
+	}
+	if app.RateLimit.Enabled && (app.RateLimit.QPS <= 0 || app.RateLimit.Burst <= 0) {
+		problems = append(problems, "app.rate_limit.qps and app.rate_limit.burst must be greater than 0 when rate limit is enabled")
+	}
+	return
+}
+
+func (ds *DatasourceConfig) validate(key string) (problems []string) {
+	switch ds.Type {
+	case "mysql", "postgres", "sqlite":
+	default:
+		problems = append(problems, fmt.Sprintf("%s.type must be one of mysql, postgres, sqlite, got %q", key, ds.Type))
+	}
+	if ds.Master.DSN == "" {
+		problems = append(problems, key+".master.dsn is required")
+	}
+	for i, replica := range ds.Replicas {
+		if replica.DSN == "" {
+			problems = append(problems, fmt.Sprintf("%s.replicas[%d].dsn is required", key, i))
+		}
+		if replica.Weight < 0 {
+			problems = append(problems, fmt.Sprintf("%s.replicas[%d].weight must not be negative", key, i))
+		}
+	}
+	return
+}
+
+// normalize 补全配置中可以省略的部分
+func (conf *configuration) normalize() {
+	db := conf.Database
+	db.DatasourceConfig.normalize()
+	for name, ds := range db.Datasources {
+		ds.normalize()
+		db.Datasources[name] = ds
+	}
+	if db.HealthCheckInterval <= 0 {
+		db.HealthCheckInterval = 5 * time.Second
+	}
+	if db.MaxReplicationLag <= 0 {
+		db.MaxReplicationLag = 10 * time.Second
+	}
+	if db.ReadYourWritesWindow <= 0 {
+		db.ReadYourWritesWindow = 5 * time.Second
+	}
+	if conf.Redis.Mode == "" {
+		conf.Redis.Mode = "standalone"
+	}
+	if len(conf.Redis.Addrs) == 0 && conf.Redis.Addr != "" {
+		conf.Redis.Addrs = []string{conf.Redis.Addr}
+	}
+	if conf.Redis.KeyPrefix == "" {
+		conf.Redis.KeyPrefix = "GOMALL"
+	}
+	httpClient := &conf.App.HttpClient
+	httpClient.HttpClientOption.inherit(HttpClientOption{
+		Timeout:             5 * time.Second,
+		MaxIdleConnsPerHost: 50,
+		MaxConnsPerHost:     50,
+		MaxRetries:          new(int),
+		RetryBackoff:        100 * time.Millisecond,
+		MaxRetryBackoff:     2 * time.Second,
+		BreakerFailures:     new(int),
+		BreakerOpenTimeout:  30 * time.Second,
+	})
+	for i := range httpClient.Hosts {
+		httpClient.Hosts[i].inherit(httpClient.HttpClientOption)
+	}
+	if conf.App.Log.DebugSignMaxTTL <= 0 {
+		conf.App.Log.DebugSignMaxTTL = time.Hour
+	}
+	if conf.App.Signature.ClockSkew <= 0 {
+		conf.App.Signature.ClockSkew = 5 * time.Minute
+	}
+	if conf.App.Signature.MaxBodySize <= 0 {
+		conf.App.Signature.MaxBodySize = 1 << 20
+	}
+	idempotency := &conf.App.Idempotency
+	if idempotency.TTL <= 0 {
+		idempotency.TTL = 24 * time.Hour
+	}
+	if idempotency.ProcessingTTL <= 0 {
+		idempotency.ProcessingTTL = time.Minute
+	}
+	if idempotency.MaxBodySize <= 0 {
+		idempotency.MaxBodySize = 1 << 20
+	}
+	cache := &conf.Redis.Cache
+	if cache.LocalTTL <= 0 {
+		cache.LocalTTL = 10 * time.Second
+	}
+	if cache.NegativeTTL <= 0 {
+		cache.NegativeTTL = time.Minute
+	}
+}
+
+func (ds *DatasourceConfig) normalize() {
+	if ds.Type == "" {
+		ds.Type = "mysql"
+	}
+	// slave 是只有一个从库时的简化配置, 统一转换成 replicas
+	if len(ds.Replicas) == 0 && ds.Slave.DSN != "" {
+		ds.Replicas = []ReplicaOption{{DbConnectOption: ds.Slave, Weight: 1}}
+	}
+	// 连接的数据库类型与数据源保持一致
+	ds.Master.Type = ds.Type
+	ds.Slave.Type = ds.Type
+	for i := range ds.Replicas {
+		ds.Replicas[i].Type = ds.Type
+		if ds.Replicas[i].Weight == 0 {
+			ds.Replicas[i].Weight = 1
+		}
+	}
+}
+
+func (option *HttpClientOption) validate(key string) (problems []string) {
+	if *option.MaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("%s.max_retries must not be negative", key))
+	}
+	if *option.BreakerFailures < 0 {
+		problems = append(problems, fmt.Sprintf("%s.breaker_failures must not be negative", key))
+	}
+	return
+}
+
+// inherit 没有配置的项使用 parent 中的值
+func (option *HttpClientOption) inherit(parent HttpClientOption) {
+	if option.Timeout <= 0 {
+		option.Timeout = parent.Timeout
+	}
+	if option.MaxIdleConnsPerHost <= 0 {
+		option.MaxIdleConnsPerHost = parent.MaxIdleConnsPerHost
+	}
+	if option.MaxConnsPerHost <= 0 {
+		option.MaxConnsPerHost = parent.MaxConnsPerHost
+	}
+	if option.MaxRetries == nil {
+		option.MaxRetries = parent.MaxRetries
+	}
+	if option.RetryBackoff <= 0 {
+		option.RetryBackoff = parent.RetryBackoff
+	}
+	if option.MaxRetryBackoff <= 0 {
+		option.MaxRetryBackoff = parent.MaxRetryBackoff
+	}
+	if option.BreakerFailures == nil {
+		option.BreakerFailures = parent.BreakerFailures
+	}
+	if option.BreakerOpenTimeout <= 0 {
+		option.BreakerOpenTimeout = parent.BreakerOpenTimeout
+	}
+}
+
+func (r *redisConfig) validate() (problems []string) {
+	switch r.Mode {
+	case "standalone":
+		if len(r.Addrs) == 0 {
+			problems = append(problems, "redis.addr is required")
+		} else if len(r.Addrs) > 1 {
+			problems = append(problems, "redis.addrs must have only one address in standalone mode")
+		}
+	case "sentinel":
+		if len(r.Addrs) == 0 {
+			problems = append(problems, "redis.addrs is required in sentinel mode")
+		}
+		if r.MasterName == "" {
+			problems = append(problems, "redis.master_name is required in sentinel mode")
+		}
+	case "cluster":
+		if len(r.Addrs) == 0 {
+			problems = append(problems, "redis.addrs is required in cluster mode")
+		}
+		if r.DB != 0 {
+			problems = append(problems, "redis.db must be 0 in cluster mode")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("redis.mode must be one of standalone, sentinel, cluster, got %q", r.Mode))
+	}
+	if strings.ContainsAny(r.KeyPrefix, "{}") {
+		// 前缀中的花括号会被当成 hash tag, 让所有的键都落到同一个 slot
+		problems = append(problems, "redis.key_prefix must not contain { or }")
+	}
+	return
+}
+
+func isValidLogLevel(level string) bool {
+	switch level {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
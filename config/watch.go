@@ -0,0 +1,55 @@
+package config
+
+import (
+	"log"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 配置热更新, 只有从外部文件加载配置时才会开启
+// 只有日志级别、限流、分页这些可以安全变更的配置会热更新, 数据库、Redis这类连接配置需要重启服务才能生效
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   []func()
+)
+
+// OnChange 订阅配置变更, 配置热更新生效后会依次调用订阅者
+// 订阅者在回调中通过 config.App() 读取新的配置
+func OnChange(fn func()) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+func watchConfig() {
+	vp.OnConfigChange(func(e fsnotify.Event) {
+		reload()
+	})
+	vp.WatchConfig()
+}
+
+func reload() {
+	conf, err := loadConfiguration(vp)
+	if err != nil {
+		// 新配置不合法时保持原配置不变
+		log.Printf("config hot reload failed, keep the current configuration: %v", err)
+		return
+	}
+	// 复制一份再整体替换, 避免读配置时读到更新了一半的数据
+	newApp := *App()
+	newApp.Log.Level = conf.App.Log.Level
+	newApp.Pagination = conf.App.Pagination
+	newApp.RateLimit = conf.App.RateLimit
+	currentApp.Store(&newApp)
+
+	subscribersMu.Lock()
+	fns := make([]func(), len(subscribers))
+	copy(fns, subscribers)
+	subscribersMu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	log.Println("config hot reload success")
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/config"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// 旁路缓存(Cache-Aside): 进程内LRU --> Redis --> loader(通常是查数据库)
+//
+//	user, err := cache.Get(ctx, key, time.Hour, func(ctx context.Context) (*model.User, error) {
+//		// 查询数据库, 数据不存在时返回 cache.ErrNotFound
+//	})
+//
+// 同一个key并发未命中时只有一个请求会执行loader, 其他请求等待并共享结果
+// 数据不存在时缓存空值, 避免不存在的key每次都穿透到数据库
+// 数据更新后调用 Invalidate 删除缓存, 其他实例的本地缓存最多在 redis.cache.local_ttl 内不一致
+
+// ErrNotFound loader 查不到数据时返回这个错误, 会被缓存为空值; Get 命中空值时也返回这个错误
+var ErrNotFound = errors.New("cache: record not found")
+
+// notFoundPlaceholder 空值的占位内容, 不是合法的JSON, 不会与正常缓存的数据冲突
+const notFoundPlaceholder = "<not-found>"
+
+var (
+	_localCache *localCache
+	_loadGroup  singleflight.Group
+)
+
+func init() {
+	_localCache = newLocalCache(config.Redis.Cache.LocalSize)
+}
+
+// Get 按 key 读取缓存, 未命中时调用 loader 加载数据并写入缓存, ttl 是 Redis 中缓存的过期时间
+func Get[T any](ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (*T, error)) (*T, error) {
+	data, err := getOrLoad(ctx, key, ttl, func(ctx context.Context) ([]byte, error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, ErrNotFound
+		}
+		return json.Marshal(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	value := new(T)
+	if err = json.Unmarshal(data, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Invalidate 删除本地和 Redis 中的缓存, 在数据更新后调用
+func Invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		_localCache.delete(key)
+	}
+	// 逐个删除, 集群模式下不同 slot 的键不能在一个 DEL 命令中删除
+	_, err := Redis().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error(ctx, "CACHE_INVALIDATE_ERROR", "keys", keys, "err", err)
+		return err
+	}
+	return nil
+}
+
+func getOrLoad(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if data, ok := _localCache.get(key); ok {
+		return decodeCached(data)
+	}
+	result, err, _ := _loadGroup.Do(key, func() (interface{}, error) {
+		// 共享结果的请求不应该因为第一个请求被取消而失败
+		loadCtx := context.WithoutCancel(ctx)
+		data, err := Redis().Get(loadCtx, key).Bytes()
+		if err == nil {
+			_localCache.set(key, data, localTTL(ttl))
+			return data, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			// Redis 不可用时降级为直接调用 loader
+			logger.Error(ctx, "CACHE_GET_ERROR", "key", key, "err", err)
+		}
+		data, err = loader(loadCtx)
+		if errors.Is(err, ErrNotFound) {
+			data, ttl = []byte(notFoundPlaceholder), config.Redis.Cache.NegativeTTL
+		} else if err != nil {
+			return nil, err
+		}
+		if setErr := Redis().Set(loadCtx, key, data, jitterTTL(ttl)).Err(); setErr != nil {
+			logger.Error(ctx, "CACHE_SET_ERROR", "key", key, "err", setErr)
+		}
+		_localCache.set(key, data, localTTL(ttl))
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeCached(result.([]byte))
+}
+
+func decodeCached(data []byte) ([]byte, error) {
+	if string(data) == notFoundPlaceholder {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// jitterTTL 过期时间随机增加一部分, 避免同时写入的缓存同时过期
+func jitterTTL(ttl time.Duration) time.Duration {
+	jitter := config.Redis.Cache.TTLJitter
+	if jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Float64()*jitter*float64(ttl))
+}
+
+func localTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < config.Redis.Cache.LocalTTL {
+		return ttl
+	}
+	return config.Redis.Cache.LocalTTL
+}
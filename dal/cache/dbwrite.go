@@ -0,0 +1,21 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/enum"
+)
+
+// SetUserRecentDBWrite 标记用户在时间窗口内发生过数据库写操作, 让用户的后续请求在窗口内读主库
+func SetUserRecentDBWrite(ctx context.Context, userId int64, window time.Duration) error {
+	redisKey := prefixedKey(enum.REDIS_KEY_DB_RECENT_WRITE, userId)
+	return Redis().Set(ctx, redisKey, 1, window).Err()
+}
+
+// HasUserRecentDBWrite 用户在时间窗口内是否发生过数据库写操作
+func HasUserRecentDBWrite(ctx context.Context, userId int64) (bool, error) {
+	redisKey := prefixedKey(enum.REDIS_KEY_DB_RECENT_WRITE, userId)
+	n, err := Redis().Exists(ctx, redisKey).Result()
+	return n > 0, err
+}
@@ -3,7 +3,6 @@ package cache
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/go-study-lab/go-mall/common/enum"
 	"github.com/go-study-lab/go-mall/common/logger"
@@ -16,7 +15,7 @@ type DummyDemoOrder struct {
 }
 
 func SetDemoOrderStruct(ctx context.Context, demoOrder *do.DemoOrder) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEMO_ORDER_DETAIL, demoOrder.OrderNo)
+	redisKey := prefixedKey(enum.REDIS_KEY_DEMO_ORDER_DETAIL, demoOrder.OrderNo)
 	data := struct {
 		OrderNo string `redis:"orderNo"`
 		UserId  int64  `redis:"userId"`
@@ -33,7 +32,7 @@ func SetDemoOrderStruct(ctx context.Context, demoOrder *do.DemoOrder) error {
 }
 
 func GetDemoOrderStruct(ctx context.Context, orderNo string) (*DummyDemoOrder, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEMO_ORDER_DETAIL, orderNo)
+	redisKey := prefixedKey(enum.REDIS_KEY_DEMO_ORDER_DETAIL, orderNo)
 	data := new(DummyDemoOrder)
 	err := Redis().HGetAll(ctx, redisKey).Scan(&data)
 	Redis().Get(ctx, redisKey).String()
@@ -47,7 +46,7 @@ func GetDemoOrderStruct(ctx context.Context, orderNo string) (*DummyDemoOrder, e
 
 func SetDemoOrder(ctx context.Context, demoOrder *do.DemoOrder) error {
 	jsonDataBytes, _ := json.Marshal(demoOrder)
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEMO_ORDER_DETAIL, demoOrder.OrderNo)
+	redisKey := prefixedKey(enum.REDIS_KEY_DEMO_ORDER_DETAIL, demoOrder.OrderNo)
 	_, err := Redis().Set(ctx, redisKey, jsonDataBytes, 0).Result()
 	if err != nil {
 		logger.Error(ctx, "redis error", "err", err)
@@ -57,7 +56,7 @@ func SetDemoOrder(ctx context.Context, demoOrder *do.DemoOrder) error {
 }
 
 func GetDemoOrder(ctx context.Context, orderNo string) (*do.DemoOrder, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_DEMO_ORDER_DETAIL, orderNo)
+	redisKey := prefixedKey(enum.REDIS_KEY_DEMO_ORDER_DETAIL, orderNo)
 	jsonBytes, err := Redis().Get(ctx, redisKey).Bytes()
 	if err != nil {
 		logger.Error(ctx, "redis error", "err", err)
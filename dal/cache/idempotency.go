@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/enum"
+	"github.com/redis/go-redis/v9"
+)
+
+// 写接口的 Idempotency-Key 记录, 用 Hash 保存:
+// token 处理请求的持有者标识, body_hash 第一次请求的请求体摘要,
+// 请求处理完成后再写入 status、content_type 和 body, 没有 status 说明请求还在处理中
+
+var (
+	// idempotencyAcquireScript 记录不存在时创建处理中的记录
+	idempotencyAcquireScript = redis.NewScript(`
+if redis.call("HSETNX", KEYS[1], "token", ARGV[1]) == 1 then
+	redis.call("HSET", KEYS[1], "body_hash", ARGV[2])
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	return 1
+end
+return 0`)
+	// idempotencyCompleteScript 持有者一致时保存响应
+	idempotencyCompleteScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "token") == ARGV[1] then
+	redis.call("HSET", KEYS[1], "status", ARGV[2], "content_type", ARGV[3], "body", ARGV[4])
+	return redis.call("PEXPIRE", KEYS[1], ARGV[5])
+end
+return 0`)
+	// idempotencyReleaseScript 持有者一致时删除记录
+	idempotencyReleaseScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "token") == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// IdempotencyRecord 第一次请求的请求体摘要和响应
+type IdempotencyRecord struct {
+	BodyHash    string
+	Status      int // 为0时请求还在处理中
+	ContentType string
+	Body        string
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
+
+// Idempotency 一个 Idempotency-Key 在Redis中的记录, 按调用方和路由区分, 不同调用方和接口的相同 Idempotency-Key 互不影响
+type Idempotency struct {
+	key   string
+	token string // 处理请求时的唯一标识, 保存响应和删除记录时校验, 不会覆盖其他请求的记录
+}
+
+// NewIdempotency caller 是调用方的标识, 登录用户用用户ID, 未登录的调用方由中间件按客户端信息生成
+func NewIdempotency(caller, route, idempotencyKey string) *Idempotency {
+	return &Idempotency{key: prefixedKey(enum.REDIS_KEY_IDEMPOTENCY, caller, route, idempotencyKey)}
+}
+
+// Acquire 第一次请求时创建处理中的记录, 记录已经存在时返回false
+func (i *Idempotency) Acquire(ctx context.Context, bodyHash string, processingTTL time.Duration) (bool, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return false, err
+	}
+	n, err := idempotencyAcquireScript.Run(ctx, Redis(), []string{i.key}, token, bodyHash, processingTTL.Milliseconds()).Int()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	i.token = token
+	return true, nil
+}
+
+// Record 获取已经存在的记录, 记录不存在时返回 nil
+func (i *Idempotency) Record(ctx context.Context) (*IdempotencyRecord, error) {
+	fields, err := Redis().HGetAll(ctx, i.key).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	record := &IdempotencyRecord{
+		BodyHash:    fields["body_hash"],
+		ContentType: fields["content_type"],
+		Body:        fields["body"],
+	}
+	if status := fields["status"]; status != "" {
+		if record.Status, err = strconv.Atoi(status); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// Complete 保存响应, 之后 ttl 内的重复请求直接返回保存的响应
+func (i *Idempotency) Complete(ctx context.Context, status int, contentType, body string, ttl time.Duration) error {
+	if i.token == "" {
+		return ErrLockNotHeld
+	}
+	n, err := idempotencyCompleteScript.Run(ctx, Redis(), []string{i.key}, i.token, status, contentType, body, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("cache: idempotency record expired while processing")
+	}
+	return nil
+}
+
+// Release 删除处理中的记录, 让客户端可以用相同的 Idempotency-Key 重试
+func (i *Idempotency) Release(ctx context.Context) error {
+	if i.token == "" {
+		return ErrLockNotHeld
+	}
+	return idempotencyReleaseScript.Run(ctx, Redis(), []string{i.key}, i.token).Err()
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// 基于Redis的分布式锁
+//
+//	lock := cache.NewLock("ORDER:PAY_"+orderNo, 10*time.Second, cache.WithWatchdog())
+//	if err := lock.Lock(ctx); err != nil {
+//		return err
+//	}
+//	defer lock.Unlock(ctx)
+//
+// 每次加锁使用唯一的持有者标识, 释放和续期时都会校验持有者, 不会误删其他请求持有的锁
+
+var ErrLockNotHeld = errors.New("cache: lock is not held")
+
+var (
+	// unlockScript 持有者一致时才删除锁
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	// refreshScript 持有者一致时才续期
+	refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+const defaultLockRetryInterval = 50 * time.Millisecond
+
+type Lock struct {
+	key           string
+	ttl           time.Duration
+	watchdog      bool
+	retryInterval time.Duration
+
+	mu           sync.Mutex
+	token        string // 持有锁时的唯一标识, 没有持有锁时为空
+	stopWatchdog chan struct{}
+}
+
+type LockOption func(*Lock)
+
+// WithWatchdog 持有锁期间每隔 ttl/3 自动续期, 用于执行时间可能超过 ttl 的操作
+// 进程退出后不再续期, 锁最多在 ttl 后自动释放
+func WithWatchdog() LockOption {
+	return func(l *Lock) {
+		l.watchdog = true
+	}
+}
+
+// WithRetryInterval 阻塞加锁时重试的间隔
+func WithRetryInterval(interval time.Duration) LockOption {
+	return func(l *Lock) {
+		l.retryInterval = interval
+	}
+}
+
+// NewLock 创建分布式锁, key 会加上项目前缀, ttl 是锁的过期时间
+func NewLock(key string, ttl time.Duration, opts ...LockOption) *Lock {
+	l := &Lock{
+		key:           prefixedKey("%s", key),
+		ttl:           ttl,
+		retryInterval: defaultLockRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// TryLock 尝试加锁一次, 锁被其他持有者占用时返回false
+func (l *Lock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token != "" {
+		return false, errors.New("cache: lock is already held by this instance")
+	}
+	token, err := newLockToken()
+	if err != nil {
+		return false, err
+	}
+	ok, err := Redis().SetNX(ctx, l.key, token, l.ttl).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	l.token = token
+	if l.watchdog {
+		l.stopWatchdog = make(chan struct{})
+		go l.renew(context.WithoutCancel(ctx), token, l.stopWatchdog)
+	}
+	return true, nil
+}
+
+// Lock 阻塞加锁, 直到加锁成功或者 ctx 超时/取消, 调用方通过 context.WithTimeout 控制最长等待时间
+func (l *Lock) Lock(ctx context.Context) error {
+	for {
+		ok, err := l.TryLock(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(l.retryInterval):
+		}
+	}
+}
+
+// Unlock 释放锁, 锁已经过期或者被其他持有者占用时返回 ErrLockNotHeld
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token == "" {
+		return ErrLockNotHeld
+	}
+	token := l.token
+	l.token = ""
+	if l.stopWatchdog != nil {
+		close(l.stopWatchdog)
+		l.stopWatchdog = nil
+	}
+	n, err := unlockScript.Run(ctx, Redis(), []string{l.key}, token).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Refresh 手动把锁的过期时间重置为 ttl, 锁已经不属于当前持有者时返回 ErrLockNotHeld
+func (l *Lock) Refresh(ctx context.Context) error {
+	l.mu.Lock()
+	token := l.token
+	l.mu.Unlock()
+	if token == "" {
+		return ErrLockNotHeld
+	}
+	return l.refresh(ctx, token)
+}
+
+func (l *Lock) refresh(ctx context.Context, token string) error {
+	n, err := refreshScript.Run(ctx, Redis(), []string{l.key}, token, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// renew 看门狗, 定期续期直到锁被释放或者锁已经丢失
+func (l *Lock) renew(ctx context.Context, token string, stop chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := l.refresh(ctx, token)
+			if errors.Is(err, ErrLockNotHeld) {
+				logger.Warn(ctx, "REDIS_LOCK_LOST", "key", l.key)
+				return
+			}
+			if err != nil {
+				// 网络抖动时下次再试, 锁在 ttl 内仍然有效
+				logger.Error(ctx, "REDIS_LOCK_RENEW_ERROR", "key", l.key, "err", err)
+			}
+		}
+	}
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,31 +2,53 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-study-lab/go-mall/config"
 	"github.com/redis/go-redis/v9"
 )
 
-var redisClient *redis.Client
+var redisClient redis.UniversalClient
 
-func Redis() *redis.Client {
+// Redis 返回Redis客户端, 按配置 redis.mode 连接单机、哨兵或者集群
+// 集群模式下一个命令或事务中操作的多个键需要落在同一个 slot, 见 enum 中键名的 hash tag
+func Redis() redis.UniversalClient {
 	return redisClient
 }
 
 func init() {
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:         config.Redis.Addr,
-		Password:     config.Redis.Password,
-		DB:           config.Redis.DB,
-		PoolSize:     config.Redis.PoolSize,
-		DialTimeout:  10 * time.Second,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		PoolTimeout:  30 * time.Second,
-	})
+	options := &redis.UniversalOptions{
+		Addrs:            config.Redis.Addrs,
+		MasterName:       config.Redis.MasterName,
+		Password:         config.Redis.Password,
+		SentinelPassword: config.Redis.SentinelPassword,
+		DB:               config.Redis.DB,
+		PoolSize:         config.Redis.PoolSize,
+		DialTimeout:      10 * time.Second,
+		ReadTimeout:      30 * time.Second,
+		WriteTimeout:     30 * time.Second,
+		PoolTimeout:      30 * time.Second,
+	}
+	switch config.Redis.Mode {
+	case "sentinel":
+		redisClient = redis.NewFailoverClient(options.Failover())
+	case "cluster":
+		redisClient = redis.NewClusterClient(options.Cluster())
+	default:
+		redisClient = redis.NewClient(options.Simple())
+	}
+}
+
+// Connect 检查Redis的连接, 服务启动时调用; 创建客户端时不会连接Redis, 不访问Redis的子命令不需要Redis也能运行
+func Connect() {
 	if err := redisClient.Ping(context.Background()).Err(); err != nil {
 		// 连接不上redis,让项目停止启动
 		panic(err)
 	}
 }
+
+// prefixedKey 生成带项目前缀的缓存键, format 使用 enum 中定义的键名
+func prefixedKey(format string, args ...interface{}) string {
+	return config.Redis.KeyPrefix + ":" + fmt.Sprintf(format, args...)
+}
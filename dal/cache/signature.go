@@ -0,0 +1,14 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/enum"
+)
+
+// UseSignatureNonce 记录签名请求用过的 nonce, nonce 在 ttl 内已经用过时返回false, 用于防止请求被重放
+func UseSignatureNonce(ctx context.Context, appKey, nonce string, ttl time.Duration) (bool, error) {
+	redisKey := prefixedKey(enum.REDIS_KEY_SIGNATURE_NONCE, appKey, nonce)
+	return Redis().SetNX(ctx, redisKey, 1, ttl).Result()
+}
@@ -10,64 +10,72 @@ import (
 	"time"
 
 	"github.com/go-study-lab/go-mall/common/enum"
-	"github.com/go-study-lab/go-mall/common/errcode"
 	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/common/util"
+	"github.com/go-study-lab/go-mall/dal/model"
 	"github.com/go-study-lab/go-mall/logic/do"
 	"github.com/redis/go-redis/v9"
 )
 
-// SetUserToken 设置用户的AccessToken 和 RefreshToken 缓存
-func SetUserToken(ctx context.Context, session *do.SessionInfo) error {
-	err := setAccessToken(ctx, session)
+// IssueUserSession 签发会话: 写入新的AccessToken和RefreshToken, 过期掉同平台旧会话的Token, 再覆盖平台上的Session
+// 所有写操作在一个 MULTI 事务中执行, 会话要么完整地创建/轮换, 要么完全不生效
+// 用户的Session、Token键使用相同的 hash tag, 集群模式下也在同一个 slot, 可以放在一个事务中
+func IssueUserSession(ctx context.Context, session *do.SessionInfo) error {
+	sessionKey := prefixedKey(enum.REDIS_KEY_USER_SESSION, session.UserId)
+	sessionData, err := json.Marshal(session)
 	if err != nil {
-		logger.Error(ctx, "redis error", "err", err)
 		return err
 	}
-	err = setRefreshToken(ctx, session)
-	if err != nil {
-		logger.Error(ctx, "redis error", "err", err)
+	return watchUserSessions(ctx, sessionKey, func(tx *redis.Tx) error {
+		oldSession, err := getUserPlatformSession(ctx, tx, sessionKey, session.Platform)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, prefixedKey(enum.REDIS_KEY_ACCESS_TOKEN, session.UserId, session.AccessToken),
+				sessionData, enum.AccessTokenDuration)
+			pipe.Set(ctx, prefixedKey(enum.REDIS_KEY_REFRESH_TOKEN, session.UserId, session.RefreshToken),
+				sessionData, enum.RefreshTokenDuration)
+			if oldSession != nil {
+				expireSessionTokens(ctx, pipe, oldSession)
+			}
+			pipe.HSet(ctx, sessionKey, session.Platform, sessionData)
+			return nil
+		})
 		return err
-	}
-	return err
+	})
 }
 
-func SetUserSession(ctx context.Context, session *do.SessionInfo) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, session.UserId)
-	sessionDataBytes, _ := json.Marshal(session)
-	err := Redis().HSet(ctx, redisKey, session.Platform, sessionDataBytes).Err()
+// watchUserSessions 在 WATCH 用户Session的前提下执行事务, 并发修改同一用户的Session导致事务失败时重试
+func watchUserSessions(ctx context.Context, sessionKey string, fn func(tx *redis.Tx) error) error {
+	var err error
+	for i := 0; i < 3; i++ {
+		err = Redis().Watch(ctx, fn, sessionKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
 	if err != nil {
 		logger.Error(ctx, "redis error", "err", err)
-		return err
 	}
 	return err
 }
 
-// DelOldSessionTokens 删除用户旧Session的Token
-func DelOldSessionTokens(ctx context.Context, session *do.SessionInfo) error {
-	//log := logger.New(ctx)
-	oldSession, err := GetUserPlatformSession(ctx, session.UserId, session.Platform)
-	if err != nil {
-		return err
-	}
-	if oldSession == nil {
-		// 没有旧Session
-		return nil
-	}
-	err = DelAccessToken(ctx, oldSession.AccessToken)
-	if err != nil {
-		return errcode.Wrap("redis error", err)
-	}
-	err = DelayDelRefreshToken(ctx, oldSession.RefreshToken)
-	if err != nil {
-		return errcode.Wrap("redis error", err)
-	}
-	return nil
+// expireSessionTokens 删除会话的AccessToken, RefreshToken 保留一段时间自己过期(用于发现RefreshToken被窃取)
+func expireSessionTokens(ctx context.Context, pipe redis.Pipeliner, session *do.SessionInfo) {
+	pipe.Del(ctx, prefixedKey(enum.REDIS_KEY_ACCESS_TOKEN, session.UserId, session.AccessToken))
+	pipe.Expire(ctx, prefixedKey(enum.REDIS_KEY_REFRESH_TOKEN, session.UserId, session.RefreshToken),
+		enum.OldRefreshTokenHoldingDuration)
 }
 
 // GetUserPlatformSession 获取用户在指定平台中的Session信息
 func GetUserPlatformSession(ctx context.Context, userId int64, platform string) (*do.SessionInfo, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
-	result, err := Redis().HGet(ctx, redisKey, platform).Result()
+	redisKey := prefixedKey(enum.REDIS_KEY_USER_SESSION, userId)
+	return getUserPlatformSession(ctx, Redis(), redisKey, platform)
+}
+
+func getUserPlatformSession(ctx context.Context, c redis.Cmdable, sessionKey, platform string) (*do.SessionInfo, error) {
+	result, err := c.HGet(ctx, sessionKey, platform).Result()
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
@@ -83,63 +91,58 @@ func GetUserPlatformSession(ctx context.Context, userId int64, platform string)
 	return session, nil
 }
 
-func setAccessToken(ctx context.Context, session *do.SessionInfo) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, session.AccessToken)
-	sessionDataBytes, _ := json.Marshal(session)
-	res, err := Redis().Set(ctx, redisKey, sessionDataBytes, enum.AccessTokenDuration).Result()
-	logger.Debug(ctx, "redis debug", "res", res, "err", err)
-	return err
-}
-
-func setRefreshToken(ctx context.Context, session *do.SessionInfo) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, session.RefreshToken)
-	sessionDataBytes, _ := json.Marshal(session)
-	return Redis().Set(ctx, redisKey, sessionDataBytes, enum.RefreshTokenDuration).Err()
-}
-
 func DelAccessToken(ctx context.Context, accessToken string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, accessToken)
+	userId, ok := tokenUserId(accessToken)
+	if !ok {
+		return nil
+	}
+	redisKey := prefixedKey(enum.REDIS_KEY_ACCESS_TOKEN, userId, accessToken)
 	return Redis().Del(ctx, redisKey).Err()
 }
 
-// DelayDelRefreshToken 刷新Token时让旧的RefreshToken 保留一段时间自己过期
-func DelayDelRefreshToken(ctx context.Context, refreshToken string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, refreshToken)
-	return Redis().Expire(ctx, redisKey, enum.OldRefreshTokenHoldingDuration).Err()
-}
-
 // DelRefreshToken 直接删除RefreshToken缓存  修改密码、退出登录时使用
 func DelRefreshToken(ctx context.Context, refreshToken string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, refreshToken)
+	userId, ok := tokenUserId(refreshToken)
+	if !ok {
+		return nil
+	}
+	redisKey := prefixedKey(enum.REDIS_KEY_REFRESH_TOKEN, userId, refreshToken)
 	return Redis().Del(ctx, redisKey).Err()
 }
 
 // DelUserSessionOnPlatform Delete user's session on specific platform
 func DelUserSessionOnPlatform(ctx context.Context, userId int64, platform string) error {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
+	redisKey := prefixedKey(enum.REDIS_KEY_USER_SESSION, userId)
 	return Redis().HDel(ctx, redisKey, platform).Err()
 }
 
-// DelUserSessions Delete user's sessions on all platform
+// DelUserSessions 删除用户在所有平台上的Session, 同时过期掉每个Session正在使用的Token, 在一个事务中完成
 func DelUserSessions(ctx context.Context, userId int64) error {
-	// 先获取所有平台上的Session信息中
-	sessions, err := GetUserAllSessions(ctx, userId)
-	if err != nil {
+	sessionKey := prefixedKey(enum.REDIS_KEY_USER_SESSION, userId)
+	return watchUserSessions(ctx, sessionKey, func(tx *redis.Tx) error {
+		sessions, err := getUserAllSessions(ctx, tx, sessionKey)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, session := range sessions {
+				expireSessionTokens(ctx, pipe, session)
+			}
+			pipe.Del(ctx, sessionKey)
+			return nil
+		})
 		return err
-	}
-	// 把所有Session中保存的正在用的Token都过期掉
-	for _, sessInfo := range sessions {
-		DelOldSessionTokens(ctx, sessInfo)
-	}
-	// Token过期完成后再删掉Session
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
-	return Redis().Del(ctx, redisKey).Err()
+	})
 }
 
 // GetUserAllSessions 获取用户在所有platform上的Session
 func GetUserAllSessions(ctx context.Context, userId int64) (map[string]*do.SessionInfo, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_USER_SESSION, userId)
-	result, err := Redis().HGetAll(ctx, redisKey).Result()
+	redisKey := prefixedKey(enum.REDIS_KEY_USER_SESSION, userId)
+	return getUserAllSessions(ctx, Redis(), redisKey)
+}
+
+func getUserAllSessions(ctx context.Context, c redis.Cmdable, sessionKey string) (map[string]*do.SessionInfo, error) {
+	result, err := c.HGetAll(ctx, sessionKey).Result()
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
@@ -160,23 +163,26 @@ func GetUserAllSessions(ctx context.Context, userId int64) (map[string]*do.Sessi
 	return sessions, nil
 }
 
-func LockTokenRefresh(ctx context.Context, refreshToken string) (bool, error) {
-	redisLockKey := fmt.Sprintf(enum.REDISKEY_TOKEN_REFRESH_LOCK, refreshToken)
-	return Redis().SetNX(ctx, redisLockKey, "locked", 10*time.Second).Result()
-}
-
-func UnlockTokenRefresh(ctx context.Context, refreshToken string) error {
-	redisLockKey := fmt.Sprintf(enum.REDISKEY_TOKEN_REFRESH_LOCK, refreshToken)
-	return Redis().Del(ctx, redisLockKey).Err()
+// NewTokenRefreshLock 刷新Token时使用的锁, 防止同一个RefreshToken被并发刷新
+// 不合法的RefreshToken也可以加锁, 随后查询RefreshToken时会按Token无效处理
+func NewTokenRefreshLock(refreshToken string) *Lock {
+	userId, _ := tokenUserId(refreshToken)
+	lockKey := fmt.Sprintf(enum.REDISKEY_TOKEN_REFRESH_LOCK, userId, refreshToken)
+	return NewLock(lockKey, 10*time.Second)
 }
 
 func GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_REFRESH_TOKEN, refreshToken)
+	session := new(do.SessionInfo)
+	userId, ok := tokenUserId(refreshToken)
+	if !ok {
+		// Token格式不合法, 与Token不存在一样处理
+		return session, nil
+	}
+	redisKey := prefixedKey(enum.REDIS_KEY_REFRESH_TOKEN, userId, refreshToken)
 	result, err := Redis().Get(ctx, redisKey).Result()
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
-	session := new(do.SessionInfo)
 	if errors.Is(err, redis.Nil) {
 		return session, nil
 	}
@@ -186,12 +192,17 @@ func GetRefreshToken(ctx context.Context, refreshToken string) (*do.SessionInfo,
 }
 
 func GetAccessToken(ctx context.Context, accessToken string) (*do.SessionInfo, error) {
-	redisKey := fmt.Sprintf(enum.REDIS_KEY_ACCESS_TOKEN, accessToken)
+	session := new(do.SessionInfo)
+	userId, ok := tokenUserId(accessToken)
+	if !ok {
+		// Token格式不合法, 与Token不存在一样处理
+		return session, nil
+	}
+	redisKey := prefixedKey(enum.REDIS_KEY_ACCESS_TOKEN, userId, accessToken)
 	result, err := Redis().Get(ctx, redisKey).Result()
 	if err != nil && err != redis.Nil {
 		return nil, err
 	}
-	session := new(do.SessionInfo)
 	if errors.Is(err, redis.Nil) {
 		return session, nil
 	}
@@ -206,13 +217,13 @@ func GetAccessToken(ctx context.Context, accessToken string) (*do.SessionInfo, e
 // @param token 重置密码的验证Token
 // @param code 验证码
 func SetPasswordResetToken(ctx context.Context, userId int64, token, code string) error {
-	redisKey := fmt.Sprintf(enum.REDISKEY_PASSWORDRESET_TOKEN, token)
+	redisKey := prefixedKey(enum.REDISKEY_PASSWORDRESET_TOKEN, token)
 	val := fmt.Sprintf("%d:%s", userId, code) // val 以 userId:code 的字符串形式存储
 	return Redis().Set(ctx, redisKey, val, enum.PasswordTokenDuration).Err()
 }
 
 func GetPasswordResetToken(ctx context.Context, token string) (userId int64, code string, err error) {
-	redisKey := fmt.Sprintf(enum.REDISKEY_PASSWORDRESET_TOKEN, token)
+	redisKey := prefixedKey(enum.REDISKEY_PASSWORDRESET_TOKEN, token)
 	val, redisErr := Redis().Get(ctx, redisKey).Result()
 	if redisErr != nil && redisErr != redis.Nil {
 		err = redisErr
@@ -229,6 +240,25 @@ func GetPasswordResetToken(ctx context.Context, token string) (userId int64, cod
 }
 
 func DelPasswordResetToken(ctx context.Context, token string) error {
-	redisKey := fmt.Sprintf(enum.REDISKEY_PASSWORDRESET_TOKEN, token)
+	redisKey := prefixedKey(enum.REDISKEY_PASSWORDRESET_TOKEN, token)
 	return Redis().Del(ctx, redisKey).Err()
 }
+
+// GetUserInfo 从缓存读取用户信息, 未命中时通过 loader 加载, 用户不存在时返回 ErrNotFound
+func GetUserInfo(ctx context.Context, userId int64, loader func(ctx context.Context) (*model.User, error)) (*model.User, error) {
+	redisKey := prefixedKey(enum.REDIS_KEY_USER_INFO, userId)
+	return Get(ctx, redisKey, enum.UserInfoCacheDuration, loader)
+}
+
+// DelUserInfo 用户信息更新后删除缓存
+func DelUserInfo(ctx context.Context, userId int64) error {
+	redisKey := prefixedKey(enum.REDIS_KEY_USER_INFO, userId)
+	return Invalidate(ctx, redisKey)
+}
+
+// tokenUserId 从Token中解析出userId, 用作键名中的 hash tag, 让同一用户的Token和Session在集群中落在同一个 slot
+// Token由客户端传入, 格式不合法时返回false, 调用方按Token不存在处理
+func tokenUserId(token string) (int64, bool) {
+	userId, err := util.ParseUserIdFromToken(token)
+	return userId, err == nil
+}
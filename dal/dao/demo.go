@@ -6,6 +6,7 @@ import (
 	"github.com/go-study-lab/go-mall/common/util"
 	"github.com/go-study-lab/go-mall/dal/model"
 	"github.com/go-study-lab/go-mall/logic/do"
+	"gorm.io/gorm"
 )
 
 type DemoDao struct {
@@ -18,19 +19,24 @@ func NewDemoDao(ctx context.Context) *DemoDao {
 
 func (demo *DemoDao) GetAllDemos() (demos []*model.DemoOrder, err error) {
 
-	err = DB().WithContext(demo.ctx).Find(&demos).Error
+	err = DB(demo.ctx).Find(&demos).Error
 	if err != nil {
 		return nil, err
 	}
 	return demos, err
 }
 
+// ListDemoOrders 分页查询订单, filter 是过滤条件
+func (demo *DemoDao) ListDemoOrders(pager Pager, filter func(*gorm.DB) *gorm.DB, opts ...PageOption) ([]*model.DemoOrder, error) {
+	return FindPage[model.DemoOrder](DB(demo.ctx).Scopes(filter), pager, opts...)
+}
+
 func (demo *DemoDao) CreateDemoOrder(demoOrder *do.DemoOrder) (*model.DemoOrder, error) {
 	model := new(model.DemoOrder)
 	err := util.CopyProperties(model, demoOrder)
 	if err != nil {
 		return nil, err
 	}
-	err = DB().WithContext(demo.ctx).Create(model).Error
+	err = DBMaster(demo.ctx).Create(model).Error
 	return model, err
 }
@@ -1,42 +1,168 @@
 package dao
 
 import (
+	"context"
+	"fmt"
+	"sync"
+
 	"github.com/go-study-lab/go-mall/config"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-var _DbMaster *gorm.DB
-var _DbSlave *gorm.DB
+// DefaultDatasource 默认数据源的名称, 对应配置中 database 下的 master 和 slave/replicas
+const DefaultDatasource = "default"
+
+// Datasource 一个数据源的主库和从库, 默认数据源直接用 dao.DB 和 dao.DBMaster, 其他数据源通过 NamedDatasource 获取
+type Datasource struct {
+	name     string
+	dbType   string
+	master   *gorm.DB
+	replicas []*replica
+}
+
+// datasources 数据源注册表, 第一次访问数据库时按配置初始化, 之后只读
+// 不在包初始化时连接数据库, 这样 secret、errcode 这类不访问数据库的子命令不需要数据库也能运行
+var (
+	datasources     map[string]*Datasource
+	datasourcesOnce sync.Once
+)
+
+// Connect 按配置连接所有数据源, 连接不上主库时panic, 服务启动时调用, 让数据库的问题在启动时就暴露出来
+func Connect() {
+	registry()
+}
+
+func registry() map[string]*Datasource {
+	datasourcesOnce.Do(func() {
+		datasources = map[string]*Datasource{}
+		registerDatasource(DefaultDatasource, config.Database.DatasourceConfig)
+		for name, dsConfig := range config.Database.Datasources {
+			registerDatasource(name, dsConfig)
+		}
+	})
+	return datasources
+}
+
+func defaultDatasource() *Datasource {
+	return registry()[DefaultDatasource]
+}
+
+// DB 返回默认数据源的只读实例
+// 从库都不可用, 或者上下文中刚发生过写操作时返回主库实例, 详见 Datasource.DB
+func DB(ctx context.Context) *gorm.DB {
+	return defaultDatasource().DB(ctx)
+}
+
+// DBMaster 返回默认数据源的主库实例
+func DBMaster(ctx context.Context) *gorm.DB {
+	return defaultDatasource().DBMaster(ctx)
+}
 
-// DB 返回只读实例
-func DB() *gorm.DB {
-	return _DbSlave
+// NamedDatasource 按名称获取配置在 database.datasources 中的数据源
+// 数据源不存在说明配置缺失, 属于程序错误, 直接panic
+func NamedDatasource(name string) *Datasource {
+	ds, ok := registry()[name]
+	if !ok {
+		panic(fmt.Sprintf("datasource %s is not configured", name))
+	}
+	return ds
+}
+
+// DB 返回数据源的只读实例, 按权重从健康的从库中选择
+// 以下情况读操作会走主库:
+// 1. 没有配置从库或者从库都不健康(连不上或者复制延迟过大)
+// 2. 上下文开启了 read-your-writes, 并且在时间窗口内发生过写操作
+// 上下文处于事务中时返回事务, 见 dao.Transaction
+func (ds *Datasource) DB(ctx context.Context) *gorm.DB {
+	if tx := ds.txFromCtx(ctx); tx != nil {
+		// 事务中的读写都在同一个事务连接上
+		return tx
+	}
+	if recentlyWritten(ctx) {
+		return ds.master.WithContext(ctx)
+	}
+	if r := ds.pickReplica(); r != nil {
+		return r.db.WithContext(ctx)
+	}
+	return ds.master.WithContext(ctx)
 }
 
-// DBMaster 返回主库实例
-func DBMaster() *gorm.DB {
-	return _DbMaster
+// DBMaster 返回数据源的主库实例, 上下文处于事务中时返回事务
+func (ds *Datasource) DBMaster(ctx context.Context) *gorm.DB {
+	if tx := ds.txFromCtx(ctx); tx != nil {
+		return tx
+	}
+	return ds.master.WithContext(ctx)
 }
-func init() {
-	_DbMaster = initDB(config.Database.Master)
-	_DbSlave = initDB(config.Database.Slave)
+
+func registerDatasource(name string, dsConfig config.DatasourceConfig) {
+	if _, exists := datasources[name]; exists {
+		panic(fmt.Sprintf("datasource %s is duplicated", name))
+	}
+	ds := &Datasource{name: name, dbType: dsConfig.Type}
+	ds.master = initDB(dsConfig.Master)
+	// 主库上的写操作会标记上下文, 用于 read-your-writes
+	registerWriteMarkCallbacks(ds.master)
+	for _, replicaOption := range dsConfig.Replicas {
+		r := &replica{weight: replicaOption.Weight, dsn: replicaOption.DSN}
+		if replicaOption.DbConnectOption == dsConfig.Master {
+			// 从库与主库配置相同时共用主库的连接池
+			r.db = ds.master
+		} else {
+			r.db = openReplica(replicaOption.DbConnectOption)
+		}
+		// 从库启动时不一定可用, 先标记为不健康, 由健康检查确认可用后再分配读请求
+		ds.replicas = append(ds.replicas, r)
+	}
+	if len(ds.replicas) > 0 {
+		go ds.healthCheckLoop(config.Database.HealthCheckInterval, config.Database.MaxReplicationLag)
+	}
+	datasources[name] = ds
 }
+
 func getDialector(t, dsn string) gorm.Dialector {
-	//switch t { //项目数据库需要加载多数据源时去掉注释
-	//case "postgres":
-	//	return postgres.Open(dsn)
-	//default:
-	//	return mysql.Open(dsn)
-	//}
-	return mysql.Open(dsn)
+	switch t {
+	case "mysql":
+		return mysql.Open(dsn)
+	case "postgres":
+		return postgres.Open(dsn)
+	case "sqlite":
+		return sqlite.Open(dsn)
+	default:
+		// 配置校验时已经限制了数据库类型, 走到这里说明新增了类型但是没有加上对应的驱动
+		panic(fmt.Sprintf("unsupported database type: %s", t))
+	}
 }
+
+// initDB 连接主库, 连接不上时panic
 func initDB(option config.DbConnectOption) *gorm.DB {
-	db, err := gorm.Open(
-		getDialector(option.Type, option.DSN),
-		&gorm.Config{
-			Logger: NewGormLogger(),
-		})
+	db := openDB(getDialector(option.Type, option.DSN), option)
+	sqlDb, _ := db.DB()
+	if err := sqlDb.Ping(); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// openReplica 打开从库但不连接, 一个从库连不上不影响服务启动, 读请求由主库和其他从库承担
+func openReplica(option config.DbConnectOption) *gorm.DB {
+	dialector := getDialector(option.Type, option.DSN)
+	if d, ok := dialector.(*mysql.Dialector); ok {
+		// 默认会在打开时查询数据库版本, 跳过这一步才不会连接数据库
+		d.Config.SkipInitializeWithVersion = true
+	}
+	return openDB(dialector, option)
+}
+
+// openDB 打开数据库并设置连接池, 不检查连接, 这里的错误只可能是配置错误, 直接panic
+func openDB(dialector gorm.Dialector, option config.DbConnectOption) *gorm.DB {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:               NewGormLogger(),
+		DisableAutomaticPing: true,
+	})
 	if err != nil {
 		panic(err)
 	}
@@ -44,8 +170,5 @@ func initDB(option config.DbConnectOption) *gorm.DB {
 	sqlDb.SetMaxOpenConns(option.MaxOpenConn)
 	sqlDb.SetMaxIdleConns(option.MaxIdleConn)
 	sqlDb.SetConnMaxLifetime(option.MaxLifeTime)
-	if err = sqlDb.Ping(); err != nil {
-		panic(err)
-	}
 	return db
 }
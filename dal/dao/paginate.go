@@ -0,0 +1,156 @@
+package dao
+
+import (
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pager 分页参数, 由 app.NewPaginaton 从请求中解析
+type Pager interface {
+	GetPageSize() int
+	Offset() int
+	IsCursorMode() bool
+	CursorValues(sort []string) ([]interface{}, error)
+	SetNextCursor(sort []string, values []interface{}) error
+	SetTotalRows(total int)
+}
+
+type pageOptions struct {
+	orderBy   []clause.OrderByColumn
+	totalRows bool
+}
+
+type PageOption func(*pageOptions)
+
+// OrderByKeys 分页的排序键, 所有键的排序方向相同, 默认按 id 倒序
+// 游标分页要求排序键的组合唯一, 所以一般以主键结尾, 如 OrderByKeys(true, "created_at", "id")
+func OrderByKeys(desc bool, columns ...string) PageOption {
+	orderBy := make([]clause.OrderByColumn, 0, len(columns))
+	for _, column := range columns {
+		orderBy = append(orderBy, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+	return OrderBy(orderBy...)
+}
+
+// OrderBy 分页的排序键, 每个键可以有不同的排序方向, 一般用 app.QuerySpec 解析出的排序
+func OrderBy(columns ...clause.OrderByColumn) PageOption {
+	return func(o *pageOptions) {
+		o.orderBy = columns
+	}
+}
+
+// WithTotalRows 查询总行数并设置到分页信息中, 大表上 COUNT 的代价很高, 不需要展示总数时不要开启
+func WithTotalRows() PageOption {
+	return func(o *pageOptions) {
+		o.totalRows = true
+	}
+}
+
+// sort 排序键和排序方向, 签名在游标中, 排序变化后旧游标失效
+func (o *pageOptions) sort() []string {
+	sort := make([]string, 0, len(o.orderBy))
+	for _, column := range o.orderBy {
+		direction := "asc"
+		if column.Desc {
+			direction = "desc"
+		}
+		sort = append(sort, column.Column.Name+" "+direction)
+	}
+	return sort
+}
+
+func newPageOptions(opts []PageOption) *pageOptions {
+	o := &pageOptions{orderBy: []clause.OrderByColumn{{Column: clause.Column{Name: "id"}, Desc: true}}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// PageScope 按分页方式给查询加上排序和 LIMIT, 偏移分页加上 OFFSET, 游标分页加上排序键大于(小于)游标的条件
+// 游标分页会多查一行用来判断是否还有下一页, 自己调用这个 Scope 查询时要去掉多出的一行, 一般直接使用 FindPage
+func PageScope(p Pager, opts ...PageOption) func(*gorm.DB) *gorm.DB {
+	o := newPageOptions(opts)
+	return func(db *gorm.DB) *gorm.DB {
+		for _, column := range o.orderBy {
+			db = db.Order(column)
+		}
+		if !p.IsCursorMode() {
+			return db.Offset(p.Offset()).Limit(p.GetPageSize())
+		}
+		values, err := p.CursorValues(o.sort())
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		if values != nil {
+			db = db.Where(keysetCondition(db, o, values))
+		}
+		return db.Limit(p.GetPageSize() + 1)
+	}
+}
+
+// keysetCondition 排在游标之后的条件, 排序键的方向可以不同
+// 例如 created_at DESC, id ASC: created_at < v1 OR (created_at = v1 AND id > v2)
+func keysetCondition(db *gorm.DB, o *pageOptions, values []interface{}) clause.Expr {
+	var (
+		ors  []string
+		vars []interface{}
+	)
+	for i, column := range o.orderBy {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, db.Statement.Quote(o.orderBy[j].Column)+" = ?")
+			vars = append(vars, values[j])
+		}
+		operator := " > ?"
+		if column.Desc {
+			operator = " < ?"
+		}
+		ands = append(ands, db.Statement.Quote(column.Column)+operator)
+		vars = append(vars, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return clause.Expr{SQL: "(" + strings.Join(ors, " OR ") + ")", Vars: vars}
+}
+
+// FindPage 按分页参数查询一页数据, 游标分页时还有下一页就设置 next_cursor
+//
+//	pagination := app.NewPaginaton(c)
+//	orders, err := dao.FindPage[model.DemoOrder](DB(ctx).Where("user_id = ?", userId), pagination)
+func FindPage[T any](db *gorm.DB, p Pager, opts ...PageOption) ([]*T, error) {
+	o := newPageOptions(opts)
+	if o.totalRows {
+		var total int64
+		if err := db.Session(&gorm.Session{}).Model(new(T)).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		p.SetTotalRows(int(total))
+	}
+	var rows []*T
+	tx := db.Scopes(PageScope(p, opts...)).Find(&rows)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if !p.IsCursorMode() || len(rows) <= p.GetPageSize() {
+		return rows, nil
+	}
+	rows = rows[:p.GetPageSize()]
+	last := reflect.ValueOf(rows[len(rows)-1]).Elem()
+	values := make([]interface{}, 0, len(o.orderBy))
+	for _, column := range o.orderBy {
+		field := tx.Statement.Schema.LookUpField(column.Column.Name)
+		if field == nil {
+			return nil, gorm.ErrInvalidField
+		}
+		value, _ := field.ValueOf(tx.Statement.Context, last)
+		values = append(values, value)
+	}
+	if err := p.SetNextCursor(o.sort(), values); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,85 @@
+package dao
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-study-lab/go-mall/config"
+	"gorm.io/gorm"
+)
+
+// read-your-writes: 主从复制有延迟, 刚写入主库的数据立即去从库读可能读不到
+// 上下文开启 read-your-writes 后, 主库上的写操作会在上下文中做标记,
+// 在 database.read_your_writes_window 时间窗口内, 同一上下文中的读操作都会走主库
+
+// ReadYourWritesKey 上下文中存放写操作标记的键, gin.Context 中可以通过 c.Set(ReadYourWritesKey, NewWriteMarker()) 开启
+const ReadYourWritesKey = "db_read_your_writes"
+
+// WriteMarker 记录上下文中最近一次写操作的时间
+type WriteMarker struct {
+	lastWriteAt atomic.Int64
+}
+
+func NewWriteMarker() *WriteMarker {
+	return new(WriteMarker)
+}
+
+// Mark 标记发生了写操作, 用户级别的 read-your-writes 在请求认证通过后也用它恢复标记
+func (m *WriteMarker) Mark() {
+	m.lastWriteAt.Store(time.Now().UnixNano())
+}
+
+// LastWriteAt 最近一次写操作的时间, 没有发生过写操作时返回零值
+func (m *WriteMarker) LastWriteAt() time.Time {
+	lastWriteAt := m.lastWriteAt.Load()
+	if lastWriteAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastWriteAt)
+}
+
+// Written 在时间窗口内是否发生过写操作
+func (m *WriteMarker) Written() bool {
+	lastWriteAt := m.lastWriteAt.Load()
+	if lastWriteAt == 0 {
+		return false
+	}
+	return time.Since(time.Unix(0, lastWriteAt)) < config.Database.ReadYourWritesWindow
+}
+
+// WithReadYourWrites 为非gin请求的上下文(如异步任务)开启 read-your-writes
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ReadYourWritesKey, NewWriteMarker())
+}
+
+// WriteMarkerFromCtx 获取上下文中的写操作标记, 上下文没有开启 read-your-writes 时返回nil
+func WriteMarkerFromCtx(ctx context.Context) *WriteMarker {
+	if ctx == nil {
+		return nil
+	}
+	marker, _ := ctx.Value(ReadYourWritesKey).(*WriteMarker)
+	return marker
+}
+
+func recentlyWritten(ctx context.Context) bool {
+	marker := WriteMarkerFromCtx(ctx)
+	return marker != nil && marker.Written()
+}
+
+// registerWriteMarkCallbacks 在主库的增删改操作完成后标记上下文
+func registerWriteMarkCallbacks(db *gorm.DB) {
+	markWrite := func(tx *gorm.DB) {
+		if tx.Error != nil || tx.Statement.RowsAffected == 0 {
+			return
+		}
+		if marker := WriteMarkerFromCtx(tx.Statement.Context); marker != nil {
+			marker.Mark()
+		}
+	}
+	callback := db.Callback()
+	callback.Create().After("gorm:create").Register("gomall:mark_write", markWrite)
+	callback.Update().After("gorm:update").Register("gomall:mark_write", markWrite)
+	callback.Delete().After("gorm:delete").Register("gomall:mark_write", markWrite)
+	callback.Raw().After("gorm:raw").Register("gomall:mark_write", markWrite)
+}
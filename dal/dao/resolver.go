@@ -0,0 +1,222 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/logger"
+	"gorm.io/gorm"
+)
+
+// 读写分离: 多个从库按权重分配读请求, 定期检查从库的连通性和复制延迟, 不健康的从库暂停使用
+
+type replica struct {
+	db      *gorm.DB
+	dsn     string
+	weight  int
+	healthy atomic.Bool
+	checked bool // 是否做过健康检查, 只在健康检查的协程中访问
+	// lagUnknown 上次检查时是否查不到复制延迟, 只在健康检查的协程中访问, 用于只在状态变化时记录日志
+	lagUnknown bool
+}
+
+// pickReplica 按权重从健康的从库中随机选择一个, 没有健康的从库时返回nil
+func (ds *Datasource) pickReplica() *replica {
+	totalWeight := 0
+	for _, r := range ds.replicas {
+		if r.healthy.Load() {
+			totalWeight += r.weight
+		}
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	n := rand.Intn(totalWeight)
+	for _, r := range ds.replicas {
+		if !r.healthy.Load() {
+			continue
+		}
+		if n < r.weight {
+			return r
+		}
+		n -= r.weight
+	}
+	return nil
+}
+
+// healthCheckLoop 启动后立即检查一次, 可用的从库不用等到下一个检查周期才开始承担读请求
+func (ds *Datasource) healthCheckLoop(interval, maxLag time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range ds.replicas {
+			ds.checkReplica(r, interval, maxLag)
+		}
+		<-ticker.C
+	}
+}
+
+// checkReplica 检查从库的连通性和复制延迟, 状态变化时记录日志
+func (ds *Datasource) checkReplica(r *replica, timeout, maxLag time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	healthy := true
+	var reason string
+	var lag time.Duration
+	var err error
+	if r.db == ds.master {
+		// 与主库共用连接池时只需要检查连通性
+		err = ds.ping(ctx, r.db)
+	} else {
+		lag, err = ds.replicationLag(ctx, r.db)
+	}
+	if lagUnknown := errors.Is(err, errReplicationLagUnknown); lagUnknown != r.lagUnknown {
+		r.lagUnknown = lagUnknown
+		if lagUnknown {
+			logger.Warn(ctx, "DB_REPLICA_LAG_UNKNOWN", "datasource", ds.name, "replica", maskDSN(r.dsn), "err", err)
+		}
+	}
+	if errors.Is(err, errReplicationLagUnknown) {
+		// 从库能连通, 只是查不到复制延迟, 按延迟未知处理, 继续使用
+		err = nil
+	}
+	if err != nil {
+		healthy = false
+		reason = err.Error()
+	} else if lag > maxLag {
+		healthy = false
+		reason = "replication lag " + lag.String() + " exceeds " + maxLag.String()
+	}
+	// 从库初始状态是不健康, 第一次检查时不健康也要记录日志, 检查通过则不算恢复
+	previous, firstCheck := r.healthy.Swap(healthy), !r.checked
+	r.checked = true
+	switch {
+	case healthy && !previous && !firstCheck:
+		logger.Info(ctx, "DB_REPLICA_RECOVERED", "datasource", ds.name, "replica", maskDSN(r.dsn))
+	case !healthy && (previous || firstCheck):
+		logger.Error(ctx, "DB_REPLICA_UNHEALTHY", "datasource", ds.name, "replica", maskDSN(r.dsn), "reason", reason)
+	}
+}
+
+// replicationLag 查询从库的复制延迟, 连接不上或者复制已停止时返回错误, 不是从库时延迟为0
+// 能连通但复制状态查询失败时(比如MySQL账号缺少 REPLICATION CLIENT 权限)返回 errReplicationLagUnknown
+func (ds *Datasource) replicationLag(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	if err := ds.ping(ctx, db); err != nil {
+		return 0, err
+	}
+	sqlDb, err := db.DB()
+	if err != nil {
+		return 0, err
+	}
+	var lag time.Duration
+	switch ds.dbType {
+	case "mysql":
+		lag, err = mysqlReplicationLag(ctx, sqlDb)
+	case "postgres":
+		lag, err = postgresReplicationLag(ctx, sqlDb)
+	}
+	if err != nil && !errors.Is(err, errReplicationStopped) && !isConnectionError(err) {
+		return 0, fmt.Errorf("%w: %v", errReplicationLagUnknown, err)
+	}
+	return lag, err
+}
+
+// isConnectionError 查询过程中连接断开或者超时, 与查询本身的错误(权限、语法)区分开
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr)
+}
+
+func postgresReplicationLag(ctx context.Context, sqlDb *sql.DB) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := sqlDb.QueryRowContext(ctx,
+		"SELECT EXTRACT(EPOCH FROM (now() - pg_last_xact_replay_timestamp()))").Scan(&seconds)
+	if err != nil || !seconds.Valid {
+		// 在主库上执行时结果为NULL
+		return 0, err
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
+
+func (ds *Datasource) ping(ctx context.Context, db *gorm.DB) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.PingContext(ctx)
+}
+
+func mysqlReplicationLag(ctx context.Context, sqlDb *sql.DB) (time.Duration, error) {
+	// MySQL 8.0.22 开始使用 SHOW REPLICA STATUS, 低版本只支持 SHOW SLAVE STATUS
+	rows, err := sqlDb.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = sqlDb.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		// 没有复制状态, 不是从库
+		return 0, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if values[i] == nil {
+			// 复制线程没有运行, 数据可能已经严重落后
+			return 0, errReplicationStopped
+		}
+		seconds, err := strconv.Atoi(string(values[i]))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, nil
+}
+
+var (
+	errReplicationStopped    = errors.New("replication is not running")
+	errReplicationLagUnknown = errors.New("replication lag unknown")
+)
+
+// maskDSN 日志中隐藏DSN里的密码
+func maskDSN(dsn string) string {
+	if at := strings.LastIndex(dsn, "@"); at > 0 {
+		if colon := strings.Index(dsn[:at], ":"); colon > 0 {
+			return dsn[:colon+1] + "******" + dsn[at:]
+		}
+	}
+	if i := strings.Index(dsn, "password="); i >= 0 {
+		end := strings.IndexByte(dsn[i:], ' ')
+		if end < 0 {
+			return dsn[:i] + "password=******"
+		}
+		return dsn[:i] + "password=******" + dsn[i+end:]
+	}
+	return dsn
+}
@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"context"
+
+	"github.com/go-study-lab/go-mall/common/errcode"
+	"gorm.io/gorm"
+)
+
+// 事务管理: 事务通过上下文传递, 在事务上下文中创建的DAO会自动加入事务, 领域服务可以把多个DAO操作组合成原子操作
+//
+//	err := dao.Transaction(ctx, func(txCtx context.Context) error {
+//		if err := dao.NewUserDao(txCtx).UpdateUser(user); err != nil {
+//			return err
+//		}
+//		_, err := dao.NewDemoDao(txCtx).CreateDemoOrder(order)
+//		return err
+//	})
+
+// Transaction 在默认数据源的主库上开启事务执行fn
+// fn 返回错误(包括 errcode.AppError)或者发生panic时回滚事务, panic会在回滚后继续抛出
+// 上下文已经处于事务中时使用SavePoint实现嵌套事务, 嵌套的fn出错只回滚到SavePoint
+func Transaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	return defaultDatasource().Transaction(ctx, fn)
+}
+
+// Transaction 在数据源的主库上开启事务执行fn, 说明见 dao.Transaction
+func (ds *Datasource) Transaction(ctx context.Context, fn func(txCtx context.Context) error) error {
+	db := ds.master.WithContext(ctx)
+	var hooks *txHooks
+	if tx := ds.txFromCtx(ctx); tx != nil {
+		// 在已有事务上调用Transaction, GORM会使用SavePoint
+		db = tx
+	} else {
+		hooks = new(txHooks)
+		ctx = context.WithValue(ctx, ds.txHooksCtxKey(), hooks)
+	}
+	// fn 返回的错误原样返回, 调用方可以继续用 errors.Is 判断业务错误
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txCtx := context.WithValue(ctx, ds.txCtxKey(), tx)
+		return normalizeTxError(fn(txCtx))
+	})
+	if err == nil && hooks != nil {
+		// 最外层事务提交成功后执行注册的回调
+		for _, hook := range hooks.afterCommit {
+			hook()
+		}
+	}
+	return err
+}
+
+// txHooks 事务提交后要执行的回调, 嵌套事务与外层事务共用
+type txHooks struct {
+	afterCommit []func()
+}
+
+// AfterCommit 注册在默认数据源的事务提交后执行的回调, 不在事务中时立即执行
+// 典型场景是更新数据后删除缓存, 避免事务提交前其他请求把旧数据重新写入缓存
+// 嵌套事务回滚到SavePoint时其中注册的回调仍会在外层事务提交后执行, 回调需要是幂等的
+func AfterCommit(ctx context.Context, fn func()) {
+	defaultDatasource().AfterCommit(ctx, fn)
+}
+
+// AfterCommit 注册在数据源的事务提交后执行的回调, 说明见 dao.AfterCommit
+func (ds *Datasource) AfterCommit(ctx context.Context, fn func()) {
+	hooks, _ := ctx.Value(ds.txHooksCtxKey()).(*txHooks)
+	if hooks == nil || ds.txFromCtx(ctx) == nil {
+		fn()
+		return
+	}
+	hooks.afterCommit = append(hooks.afterCommit, fn)
+}
+
+func (ds *Datasource) txCtxKey() string {
+	return "db_transaction_" + ds.name
+}
+
+func (ds *Datasource) txHooksCtxKey() string {
+	return "db_transaction_hooks_" + ds.name
+}
+
+// txFromCtx 获取上下文中当前数据源的事务, 不在事务中时返回nil
+func (ds *Datasource) txFromCtx(ctx context.Context) *gorm.DB {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(ds.txCtxKey()).(*gorm.DB)
+	if tx == nil {
+		return nil
+	}
+	return tx.WithContext(ctx)
+}
+
+// normalizeTxError 避免 fn 返回值为nil的 *errcode.AppError 时, 因为error接口不为nil导致事务被回滚
+func normalizeTxError(err error) error {
+	if appErr, ok := err.(*errcode.AppError); ok && appErr == nil {
+		return nil
+	}
+	return err
+}
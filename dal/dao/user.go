@@ -5,7 +5,9 @@ import (
 	"errors"
 
 	"github.com/go-study-lab/go-mall/common/errcode"
+	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/util"
+	"github.com/go-study-lab/go-mall/dal/cache"
 	"github.com/go-study-lab/go-mall/dal/model"
 	"github.com/go-study-lab/go-mall/logic/do"
 	"gorm.io/gorm"
@@ -28,7 +30,7 @@ func (ud *UserDao) CreateUser(userInfo *do.UserBaseInfo, userPasswordHash string
 	}
 	userModel.Password = userPasswordHash
 
-	err = DBMaster().WithContext(ud.ctx).Create(userModel).Error
+	err = DBMaster(ud.ctx).Create(userModel).Error
 	if err != nil {
 		err = errcode.Wrap("UserDaoCreateUserError", err)
 		return nil, err
@@ -38,23 +40,56 @@ func (ud *UserDao) CreateUser(userInfo *do.UserBaseInfo, userPasswordHash string
 
 func (ud *UserDao) FindUserByLoginName(loginName string) (*model.User, error) {
 	user := new(model.User)
-	err := DB().WithContext(ud.ctx).Where(model.User{LoginName: loginName}).First(&user).Error
+	err := DB(ud.ctx).Where(model.User{LoginName: loginName}).First(&user).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 	return user, nil
 }
 
+// FindUserById 按ID查询用户, 优先读缓存, 用户不存在时返回ID为0的空用户
+// 缓存中不保存密码, 返回的用户 Password 为空; 事务中的查询直接读数据库, 保证读到的是事务内的最新数据(包含密码)
 func (ud *UserDao) FindUserById(userId int64) (*model.User, error) {
+	if defaultDatasource().txFromCtx(ud.ctx) != nil {
+		return ud.findUserById(DBMaster(ud.ctx), userId)
+	}
+	user, err := cache.GetUserInfo(ud.ctx, userId, func(ctx context.Context) (*model.User, error) {
+		// 缓存未命中时读主库, 避免从库复制延迟把刚更新前的旧数据写入缓存
+		user, err := ud.findUserById(DBMaster(ctx), userId)
+		if err != nil {
+			return nil, err
+		}
+		if user.ID == 0 {
+			return nil, cache.ErrNotFound
+		}
+		return user, nil
+	})
+	if errors.Is(err, cache.ErrNotFound) {
+		return new(model.User), nil
+	}
+	return user, err
+}
+
+func (ud *UserDao) findUserById(db *gorm.DB, userId int64) (*model.User, error) {
 	user := new(model.User)
-	err := DB().WithContext(ud.ctx).Where(model.User{ID: userId}).Find(&user).Error // Find 查找不到数据时不会返回 gorm.ErrRecordNotFound
+	err := db.Where(model.User{ID: userId}).Find(&user).Error // Find 查找不到数据时不会返回 gorm.ErrRecordNotFound
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
+// UpdateUser 更新用户信息, 在事务中调用时等事务提交后再删除缓存
 func (ud *UserDao) UpdateUser(user *model.User) error {
-	err := DBMaster().WithContext(ud.ctx).Model(user).Updates(user).Error
-	return err
+	err := DBMaster(ud.ctx).Model(user).Updates(user).Error
+	if err != nil {
+		return err
+	}
+	AfterCommit(ud.ctx, func() {
+		// 删除失败时缓存会在过期后自然失效, 不影响本次更新的结果, 记录日志便于排查读到旧数据的问题
+		if err := cache.DelUserInfo(ud.ctx, user.ID); err != nil {
+			logger.Error(ud.ctx, "UserDaoDelUserInfoError", "user_id", user.ID, "err", err)
+		}
+	})
+	return nil
 }
@@ -0,0 +1,31 @@
+package migration
+
+import (
+	"context"
+
+	"github.com/go-study-lab/go-mall/common/enum"
+	"github.com/go-study-lab/go-mall/common/logger"
+	"github.com/go-study-lab/go-mall/config"
+	"github.com/go-study-lab/go-mall/dal/dao"
+	"github.com/go-study-lab/go-mall/dal/model"
+)
+
+// models 开发环境自动迁移的 GORM Model, 新增表时记得加到这里, 同时补充 sql/ 下的迁移文件
+var models = []interface{}{
+	&model.User{},
+	&model.DemoOrder{},
+}
+
+// AutoMigrate 开发环境开启 database.auto_migrate 时, 按 GORM Model 自动建表和补充缺少的字段、索引
+// AutoMigrate 不会删除字段也不会修改已有字段, 其他环境的表结构变更统一通过 migrate 命令执行
+func AutoMigrate(ctx context.Context) error {
+	if config.App().Env != enum.ModeDev || !config.Database.AutoMigrate {
+		return nil
+	}
+	if err := dao.DBMaster(ctx).AutoMigrate(models...); err != nil {
+		logger.Error(ctx, "DB_AUTO_MIGRATE_FAILED", "err", err)
+		return err
+	}
+	logger.Info(ctx, "DB_AUTO_MIGRATE_DONE", "models", len(models))
+	return nil
+}
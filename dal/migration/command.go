@@ -0,0 +1,147 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-study-lab/go-mall/config"
+	"github.com/go-study-lab/go-mall/dal/dao"
+)
+
+// 迁移命令, 在主程序中通过 migrate 子命令调用:
+//
+//	go-mall migrate up [-steps N]      执行未执行的迁移
+//	go-mall migrate down [-steps N]    回滚最近的迁移, 默认回滚一个版本
+//	go-mall migrate status             查看迁移的执行状态
+//	go-mall migrate create <name>      在源码目录中生成新的迁移文件
+//
+// 迁移作用于默认数据源的主库
+
+const usage = `usage: go-mall migrate <command> [flags]
+
+commands:
+  up [-steps N]             apply pending migrations, all of them by default
+  down [-steps N]           roll back the latest applied migrations, one by default
+  status                    show migration status
+  create [-dir DIR] <name>  create empty up/down migration files for every database type
+`
+
+var migrationNameRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// RunCommand 执行 migrate 子命令, args 是 migrate 之后的参数
+func RunCommand(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(out, usage)
+		return errors.New("missing migrate command")
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	steps := flags.Int("steps", 0, "number of migrations to apply or roll back")
+	lockTimeout := flags.Duration("lock-timeout", 5*time.Minute, "max time to wait for the migration lock")
+	dir := flags.String("dir", filepath.Join("dal", "migration", migrationDir), "migration source directory, used by create")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if args[0] == "create" {
+		if flags.NArg() != 1 {
+			return errors.New("usage: go-mall migrate create [-dir DIR] <name>")
+		}
+		return create(*dir, flags.Arg(0), out)
+	}
+
+	migrator, err := NewMigrator(dao.DBMaster(context.Background()), config.Database.Type)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *lockTimeout)
+	defer cancel()
+	switch args[0] {
+	case "up":
+		executed, err := migrator.Up(ctx, *steps)
+		for _, m := range executed {
+			fmt.Fprintf(out, "applied   %06d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(executed) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return err
+	case "down":
+		rolledBack, err := migrator.Down(ctx, *steps)
+		for _, m := range rolledBack {
+			fmt.Fprintf(out, "rolled back %06d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(rolledBack) == 0 {
+			fmt.Fprintln(out, "no applied migrations")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied at " + s.AppliedAt.Format(time.DateTime)
+			}
+			if s.Missing {
+				state += " (migration file missing)"
+			}
+			fmt.Fprintf(out, "%06d_%-40s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		fmt.Fprint(out, usage)
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+}
+
+// create 为每种数据库类型生成一对空的迁移文件, 版本号取所有类型中最大的版本号加一, 保证各类型的版本一致
+func create(dir, name string, out io.Writer) error {
+	if !migrationNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid migration name %q, only lowercase letters, digits and underscores are allowed", name)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var dbTypes []string
+	var version int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dbTypes = append(dbTypes, entry.Name())
+		migrations, err := parseMigrations(os.DirFS(dir), entry.Name())
+		if err != nil {
+			return err
+		}
+		if n := len(migrations); n > 0 && migrations[n-1].Version > version {
+			version = migrations[n-1].Version
+		}
+	}
+	if len(dbTypes) == 0 {
+		return fmt.Errorf("no database type directory found in %s", dir)
+	}
+	version++
+	for _, dbType := range dbTypes {
+		for _, direction := range []string{"up", "down"} {
+			file := filepath.Join(dir, dbType, fmt.Sprintf("%06d_%s.%s.sql", version, name, direction))
+			content := fmt.Sprintf("-- %06d_%s %s migration for %s\n", version, name, strings.ToUpper(direction), dbType)
+			if err = os.WriteFile(file, []byte(content), 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "created", file)
+		}
+	}
+	return nil
+}
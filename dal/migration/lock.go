@@ -0,0 +1,118 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/util"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 迁移锁: 多个实例同时部署时只有一个能执行迁移, 其他实例等待锁释放后再检查待执行的迁移
+// 锁是 schema_migration_lock 表中 id=1 的记录, 持有期间定期续期, 进程异常退出没有释放的锁过期后可以被抢占
+
+const (
+	lockRowId = 1
+	// lockStaleAfter 锁超过这个时间没有续期视为持有者已经退出
+	lockStaleAfter = 2 * time.Minute
+	// lockRenewInterval 持有锁期间的续期间隔
+	lockRenewInterval = 30 * time.Second
+	// lockRetryInterval 锁被占用时的重试间隔
+	lockRetryInterval = time.Second
+)
+
+var ErrLockTimeout = errors.New("timeout waiting for migration lock")
+
+type schemaMigrationLock struct {
+	Id       int       `gorm:"column:id;primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"column:owner;type:varchar(128);not null"`
+	LockedAt time.Time `gorm:"column:locked_at;not null"`
+}
+
+func (schemaMigrationLock) TableName() string {
+	return "schema_migration_lock"
+}
+
+type migrationLock struct {
+	db    *gorm.DB
+	owner string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newMigrationLock(db *gorm.DB) *migrationLock {
+	hostname, _ := os.Hostname()
+	return &migrationLock{
+		db:    db,
+		owner: fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), util.RandNumStr(6)),
+	}
+}
+
+// acquire 获取迁移锁, 锁被其他实例持有时一直等到锁释放或者 ctx 结束
+func (l *migrationLock) acquire(ctx context.Context) error {
+	for {
+		ok, err := l.tryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			l.stop = make(chan struct{})
+			l.done = make(chan struct{})
+			go l.renew()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ErrLockTimeout
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func (l *migrationLock) tryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	result := l.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&schemaMigrationLock{Id: lockRowId, Owner: l.owner, LockedAt: now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+	// 锁已存在, 持有者长时间没有续期时抢占
+	result = l.db.WithContext(ctx).Model(&schemaMigrationLock{}).
+		Where("id = ? AND locked_at < ?", lockRowId, now.Add(-lockStaleAfter)).
+		Updates(map[string]interface{}{"owner": l.owner, "locked_at": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (l *migrationLock) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.db.Model(&schemaMigrationLock{}).
+				Where("id = ? AND owner = ?", lockRowId, l.owner).
+				Update("locked_at", time.Now())
+		}
+	}
+}
+
+// release 释放迁移锁, 只删除自己持有的锁
+func (l *migrationLock) release() error {
+	close(l.stop)
+	<-l.done
+	return l.db.Where("id = ? AND owner = ?", lockRowId, l.owner).
+		Delete(&schemaMigrationLock{}).Error
+}
@@ -0,0 +1,103 @@
+package migration
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 版本化的数据库表结构迁移, 迁移文件按数据库类型放在 sql/<type>/ 目录下, 编译时内嵌到二进制中
+// 文件命名规则: {版本号}_{名称}.up.sql 和 {版本号}_{名称}.down.sql, 版本号递增, 同一版本的 up 和 down 成对出现
+
+//go:embed sql
+var migrationFiles embed.FS
+
+const migrationDir = "sql"
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// loadMigrations 读取数据库类型对应的迁移文件, 按版本号升序返回
+func loadMigrations(dbType string) ([]*Migration, error) {
+	return parseMigrations(migrationFiles, path.Join(migrationDir, dbType))
+}
+
+func parseMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migration dir %s error: %w", dir, err)
+	}
+	migrations := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			migrations[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migration version %d is duplicated: %s and %s", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	result := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up sql", m.Version, m.Name)
+		}
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// splitStatements 把迁移文件拆成单条SQL执行, 不依赖驱动的多语句支持(MySQL需要在DSN中开启multiStatements)
+// 语句以行尾的分号结束, 字符串和注释中的分号不在行尾时不受影响
+func splitStatements(content string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
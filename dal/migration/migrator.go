@@ -0,0 +1,211 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-study-lab/go-mall/common/logger"
+	"gorm.io/gorm"
+)
+
+// schemaMigration 已执行的迁移记录
+type schemaMigration struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移的执行状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Missing 数据库中有执行记录, 但是二进制中已经没有对应的迁移文件
+	Missing bool
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+// NewMigrator 创建迁移执行器, dbType 决定使用 sql/ 下哪个目录中的迁移文件
+func NewMigrator(db *gorm.DB, dbType string) (*Migrator, error) {
+	migrations, err := loadMigrations(dbType)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up 按版本号顺序执行未执行过的迁移, steps <= 0 时执行全部
+func (m *Migrator) Up(ctx context.Context, steps int) ([]*Migration, error) {
+	var executed []*Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if steps > 0 && len(executed) >= steps {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err = m.apply(ctx, migration, true); err != nil {
+				return err
+			}
+			executed = append(executed, migration)
+		}
+		return nil
+	})
+	return executed, err
+}
+
+// Down 按版本号倒序回滚最近执行的迁移, steps <= 0 时回滚一个版本
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var rolledBack []*Migration
+	err := m.withLock(ctx, func() error {
+		var records []schemaMigration
+		err := m.db.WithContext(ctx).Order("version DESC").Limit(steps).Find(&records).Error
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			migration := m.find(record.Version)
+			if migration == nil {
+				return fmt.Errorf("migration %d_%s is not found, can not roll back", record.Version, record.Name)
+			}
+			if err = m.apply(ctx, migration, false); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status 返回所有迁移的执行状态, 按版本号升序排列
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, Status{
+			Version: record.Version, Name: record.Name, Applied: true, AppliedAt: record.AppliedAt, Missing: true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// apply 在事务中执行迁移并更新执行记录
+// 注意 MySQL 的 DDL 语句会隐式提交事务, 一个迁移文件中包含多条 DDL 时中途失败需要手动处理
+func (m *Migrator) apply(ctx context.Context, migration *Migration, up bool) error {
+	content, direction := migration.Up, "up"
+	if !up {
+		content, direction = migration.Down, "down"
+	}
+	start := time.Now()
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(content) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		if up {
+			return tx.Create(&schemaMigration{
+				Version: migration.Version, Name: migration.Name, AppliedAt: time.Now(),
+			}).Error
+		}
+		return tx.Delete(&schemaMigration{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		logger.Error(ctx, "DB_MIGRATION_FAILED", "version", migration.Version, "name", migration.Name,
+			"direction", direction, "err", err)
+		return fmt.Errorf("migrate %s %d_%s error: %w", direction, migration.Version, migration.Name, err)
+	}
+	logger.Info(ctx, "DB_MIGRATION_APPLIED", "version", migration.Version, "name", migration.Name,
+		"direction", direction, "duration", time.Since(start).String())
+	return nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+	lock := newMigrationLock(m.db)
+	if err := lock.acquire(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := lock.release(); err != nil {
+			logger.Error(ctx, "DB_MIGRATION_LOCK_RELEASE_FAILED", "err", err)
+		}
+	}()
+	return fn()
+}
+
+// ensureTables 创建记录迁移版本和迁移锁的表
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	for _, table := range []interface{}{&schemaMigration{}, &schemaMigrationLock{}} {
+		if db.Migrator().HasTable(table) {
+			continue
+		}
+		if err := db.Migrator().CreateTable(table); err != nil && !db.Migrator().HasTable(table) {
+			// 多个实例同时建表时只有一个会成功, 表已经存在就可以继续
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int64]schemaMigration, error) {
+	var records []schemaMigration
+	if err := m.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
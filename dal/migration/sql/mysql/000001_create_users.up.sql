@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT COMMENT '用户ID',
+  `nickname` varchar(30) NOT NULL DEFAULT '' COMMENT '用户昵称',
+  `login_name` varchar(100) NOT NULL DEFAULT '' COMMENT '登录时使用的用户名',
+  `password` varchar(100) NOT NULL DEFAULT '' COMMENT 'bcrypt加密的登录密码',
+  `verified` tinyint NOT NULL DEFAULT 0 COMMENT '验证状态 0-未验证 1-已验证',
+  `avatar` varchar(100) NOT NULL DEFAULT '' COMMENT '用户头像',
+  `slogan` varchar(30) NOT NULL DEFAULT '' COMMENT '个性签名',
+  `is_del` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '删除状态 0-未删除 1-已删除',
+  `is_blocked` tinyint NOT NULL DEFAULT 0 COMMENT '禁用状态 0-正常 1-已禁用',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_login_name` (`login_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户表';
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `demo_orders` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL DEFAULT 0 COMMENT '用户ID',
+  `bill_money` bigint NOT NULL DEFAULT 0 COMMENT '订单金额(分)',
+  `order_no` varchar(32) NOT NULL DEFAULT '' COMMENT '订单号',
+  `state` tinyint NOT NULL DEFAULT 1 COMMENT '订单状态',
+  `paid_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00' COMMENT '支付时间',
+  `is_del` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '删除状态 0-未删除 1-已删除',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_order_no` (`order_no`),
+  KEY `idx_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Demo订单表';
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS users (
+  id BIGSERIAL PRIMARY KEY,
+  nickname VARCHAR(30) NOT NULL DEFAULT '',
+  login_name VARCHAR(100) NOT NULL DEFAULT '',
+  password VARCHAR(100) NOT NULL DEFAULT '',
+  verified SMALLINT NOT NULL DEFAULT 0,
+  avatar VARCHAR(100) NOT NULL DEFAULT '',
+  slogan VARCHAR(30) NOT NULL DEFAULT '',
+  is_del SMALLINT NOT NULL DEFAULT 0,
+  is_blocked SMALLINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_users_login_name ON users (login_name);
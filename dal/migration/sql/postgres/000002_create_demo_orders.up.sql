@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS demo_orders (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL DEFAULT 0,
+  bill_money BIGINT NOT NULL DEFAULT 0,
+  order_no VARCHAR(32) NOT NULL DEFAULT '',
+  state SMALLINT NOT NULL DEFAULT 1,
+  paid_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00',
+  is_del SMALLINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_demo_orders_order_no ON demo_orders (order_no);
+CREATE INDEX IF NOT EXISTS idx_demo_orders_user_id ON demo_orders (user_id);
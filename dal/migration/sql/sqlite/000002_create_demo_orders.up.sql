@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS demo_orders (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL DEFAULT 0,
+  bill_money INTEGER NOT NULL DEFAULT 0,
+  order_no TEXT NOT NULL DEFAULT '',
+  state INTEGER NOT NULL DEFAULT 1,
+  paid_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+  is_del INTEGER NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_demo_orders_order_no ON demo_orders (order_no);
+CREATE INDEX IF NOT EXISTS idx_demo_orders_user_id ON demo_orders (user_id);
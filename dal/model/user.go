@@ -9,7 +9,7 @@ type User struct {
 	ID        int64                 `gorm:"column:id;primary_key;AUTO_INCREMENT"`                 // 用户ID
 	Nickname  string                `gorm:"column:nickname;NOT NULL"`                             // 用户昵称
 	LoginName string                `gorm:"column:login_name;NOT NULL"`                           // 登录时使用的用户名
-	Password  string                `gorm:"column:password;NOT NULL"`                             // bcrypt加密的登录密码
+	Password  string                `gorm:"column:password;NOT NULL" json:"-"`                    // bcrypt加密的登录密码, 不参与JSON序列化, 不会写入用户信息缓存
 	Verified  int                   `gorm:"column:verified;default:0;NOT NULL"`                   // 验证状态 0-未验证 1-已验证
 	Avatar    string                `gorm:"column:avatar;NOT NULL"`                               // 用户头像
 	Slogan    string                `gorm:"column:slogan;NOT NULL"`                               // 个性签名
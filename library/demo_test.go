@@ -0,0 +1,34 @@
+package library
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-study-lab/go-mall/common/util/httptool/httpmock"
+)
+
+func TestDemoLib_TestPostCreateOrder(t *testing.T) {
+	transport := httpmock.NewTransport(httpmock.Stub{
+		Method: http.MethodPost,
+		URL:    "http://localhost:8080/building/create-demo-order",
+		Body:   `{"user_id":12345,"bill_money":20,"order_goods_id":1111110}`,
+		Response: httpmock.Response{
+			Status:  http.StatusOK,
+			Headers: map[string]string{"Content-Type": "application/json; charset=utf-8"},
+			Body:    `{"code":0,"msg":"success","data":{"user_id":12345,"bill_money":20,"order_no":"20261019000001","state":1}}`,
+		},
+	})
+	defer httpmock.Install(transport)()
+
+	result, err := NewDemoLib(context.Background()).TestPostCreateOrder()
+	if err != nil {
+		t.Fatalf("TestPostCreateOrder error: %v", err)
+	}
+	if result == nil || result.OrderNo != "20261019000001" || result.UserId != 12345 || result.BillMoney != 20 {
+		t.Errorf("unexpected order create result: %+v", result)
+	}
+	if n := len(transport.Requests()); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+}
@@ -2,7 +2,6 @@ package library
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/util/httptool"
@@ -10,6 +9,7 @@ import (
 
 // 对接 ipwhois.io 的Lib
 // Documentation: https://ipwhois.io/documentation
+// 单元测试时用 httpmock.Fixture 回放 testdata/whois 中的 golden 文件, 不需要访问外部网络
 
 type WhoisLib struct {
 	ctx context.Context
@@ -41,18 +41,18 @@ type WhoisIpDetail struct {
 
 func (whois *WhoisLib) GetHostIpDetail() (*WhoisIpDetail, error) {
 
-	httpStatusCode, respBody, err := httptool.Get(
+	reply := new(WhoisIpDetail)
+	httpStatusCode, _, err := httptool.Get(
 		whois.ctx, "https://ipwho.is",
 		httptool.WithHeaders(map[string]string{
 			"User-Agent": "curl/7.77.0",
 		}),
+		httptool.WithJSONResult(reply),
 	)
 	if err != nil {
 		logger.Error(whois.ctx, "whois request error", "err", err, "httpStatusCode", httpStatusCode)
 		return nil, err
 	}
-	reply := new(WhoisIpDetail)
-	json.Unmarshal(respBody, reply)
 
 	return reply, nil
 }
@@ -0,0 +1,27 @@
+package library
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-study-lab/go-mall/common/util/httptool/httpmock"
+)
+
+func TestWhoisLib_GetHostIpDetail(t *testing.T) {
+	transport, save, err := httpmock.Fixture("testdata/whois/get_host_ip_detail.json", "User-Agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpmock.Install(transport)()
+
+	detail, err := NewWhoisLib(context.Background()).GetHostIpDetail()
+	if err != nil {
+		t.Fatalf("GetHostIpDetail error: %v", err)
+	}
+	if err = save(); err != nil {
+		t.Fatal(err)
+	}
+	if !detail.Success || detail.Ip == "" || detail.CountryCode == "" {
+		t.Errorf("unexpected ip detail: %+v", detail)
+	}
+}
@@ -5,10 +5,12 @@ import (
 
 	"github.com/go-study-lab/go-mall/api/reply"
 	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/errcode"
 	"github.com/go-study-lab/go-mall/common/logger"
 	"github.com/go-study-lab/go-mall/common/util"
 	"github.com/go-study-lab/go-mall/dal/cache"
+	"github.com/go-study-lab/go-mall/dal/dao"
 	"github.com/go-study-lab/go-mall/logic/do"
 	"github.com/go-study-lab/go-mall/logic/domainservice"
 )
@@ -47,6 +49,19 @@ func (das *DemoAppSvc) GetDemoIdentities() ([]int64, error) {
 	return identities, nil
 }
 
+// ListDemoOrders 订单列表
+func (das *DemoAppSvc) ListDemoOrders(spec *app.QuerySpec, pager dao.Pager) ([]*reply.DemoOrder, error) {
+	demoOrders, err := das.demoDomainSvc.ListDemoOrders(spec, pager)
+	if err != nil {
+		return nil, err
+	}
+	replyDemoOrders := make([]*reply.DemoOrder, 0, len(demoOrders))
+	if err = util.CopyProperties(&replyDemoOrders, demoOrders); err != nil {
+		return nil, errcode.Wrap("demoOrderDo转换成replyDemoOrder失败", err)
+	}
+	return replyDemoOrders, nil
+}
+
 func (das *DemoAppSvc) CreateDemoOrder(orderRequest *request.DemoOrderCreate) (*reply.DemoOrder, error) {
 	demoOrderDo := new(do.DemoOrder)
 	err := util.CopyProperties(demoOrderDo, orderRequest)
@@ -3,9 +3,11 @@ package domainservice
 import (
 	"context"
 
+	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/errcode"
 	"github.com/go-study-lab/go-mall/common/util"
 	"github.com/go-study-lab/go-mall/dal/dao"
+	"github.com/go-study-lab/go-mall/dal/model"
 	"github.com/go-study-lab/go-mall/logic/do"
 )
 
@@ -38,16 +40,38 @@ func (dds *DemoDomainSvc) GetDemos() ([]*do.DemoOrder, error) {
 	return demoOrders, nil
 }
 
+// ListDemoOrders 按过滤和排序条件分页查询订单
+func (dds *DemoDomainSvc) ListDemoOrders(spec *app.QuerySpec, pager dao.Pager) ([]*do.DemoOrder, error) {
+	demos, err := dds.DemoDao.ListDemoOrders(pager, spec.FilterScope(), dao.OrderBy(spec.OrderBy()...))
+	if err != nil {
+		return nil, errcode.Wrap("list demo orders error", err)
+	}
+	demoOrders := make([]*do.DemoOrder, 0, len(demos))
+	for _, demo := range demos {
+		demoOrder := new(do.DemoOrder)
+		util.CopyProperties(demoOrder, demo)
+		demoOrders = append(demoOrders, demoOrder)
+	}
+	return demoOrders, nil
+}
+
 func (dds *DemoDomainSvc) CreateDemoOrder(demoOrder *do.DemoOrder) (*do.DemoOrder, error) {
 	// 生成订单号，随便Mock个
 	demoOrder.OrderNo = "20240627596615375920904456"
-	demoOrderModel, err := dds.DemoDao.CreateDemoOrder(demoOrder)
+	var demoOrderModel *model.DemoOrder
+	err := dao.Transaction(dds.ctx, func(txCtx context.Context) error {
+		var err error
+		demoOrderModel, err = dao.NewDemoDao(txCtx).CreateDemoOrder(demoOrder)
+		if err != nil {
+			return errcode.Wrap("创建DemoOrder失败", err)
+		}
+		// TODO1: 写订单快照
+		// 订单商品快照表的写入也放在这个事务里, 这个等后面做需求时再演示
+		return nil
+	})
 	if err != nil {
-		err = errcode.Wrap("创建DemoOrder失败", err)
 		return nil, err
 	}
-	// TODO1: 写订单快照
-	// 这里一般要在事务里写订单商品快照表, 这个等后面做需求时再演示
 	err = util.CopyProperties(demoOrder, demoOrderModel)
 	// 返回领域对象
 	return demoOrder, err
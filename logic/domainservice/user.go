@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/go-study-lab/go-mall/api/request"
+	"github.com/go-study-lab/go-mall/common/app"
 	"github.com/go-study-lab/go-mall/common/enum"
 	"github.com/go-study-lab/go-mall/common/errcode"
 	"github.com/go-study-lab/go-mall/common/logger"
@@ -43,16 +44,19 @@ func (us *UserDomainSvc) GetUserBaseInfo(userId int64) *do.UserBaseInfo {
 
 // UpdateUserBaseInfo 更新用户的基本信息
 func (us *UserDomainSvc) UpdateUserBaseInfo(request *request.UserInfoUpdate, userId int64) error {
-	user, err := us.userDao.FindUserById(userId)
-	if err != nil {
-		return err
-	}
+	// 查询和更新放在同一个事务里, 避免基于从库上的旧数据做更新
+	return dao.Transaction(us.ctx, func(txCtx context.Context) error {
+		userDao := dao.NewUserDao(txCtx)
+		user, err := userDao.FindUserById(userId)
+		if err != nil {
+			return err
+		}
 
-	user.Avatar = request.Avatar
-	user.Nickname = request.Nickname
-	user.Slogan = request.Slogan
-	err = us.userDao.UpdateUser(user)
-	return err
+		user.Avatar = request.Avatar
+		user.Nickname = request.Nickname
+		user.Slogan = request.Slogan
+		return userDao.UpdateUser(user)
+	})
 }
 
 // GenAuthToken 生成AccessToken和RefreshToken
@@ -81,20 +85,10 @@ func (us *UserDomainSvc) GenAuthToken(userId int64, platform string, sessionId s
 		err = errcode.Wrap("Token生成失败", err)
 		return nil, err
 	}
-	// 向缓存中设置AccessToken和RefreshToken的缓存
-	err = cache.SetUserToken(us.ctx, userSession)
+	// 设置新Token的缓存、过期旧Token、覆盖Session, 一次性原子完成
+	err = cache.IssueUserSession(us.ctx, userSession)
 	if err != nil {
-		err = errcode.Wrap("设置Token缓存时发生错", err)
-		return nil, err
-	}
-	err = cache.DelOldSessionTokens(us.ctx, userSession)
-	if err != nil {
-		errcode.Wrap("删除旧Token时发生错误", err)
-		return nil, err
-	}
-	err = cache.SetUserSession(us.ctx, userSession)
-	if err != nil {
-		errcode.Wrap("设置Session缓存时发生错误", err)
+		err = errcode.Wrap("设置Session缓存时发生错误", err)
 		return nil, err
 	}
 
@@ -127,16 +121,19 @@ func (us *UserDomainSvc) VerifyAccessToken(accessToken string) (*do.TokenVerify,
 }
 
 func (us *UserDomainSvc) RefreshToken(refreshToken string) (*do.TokenInfo, error) {
-	ok, err := cache.LockTokenRefresh(us.ctx, refreshToken)
-	defer cache.UnlockTokenRefresh(us.ctx, refreshToken)
+	lock := cache.NewTokenRefreshLock(refreshToken)
+	ok, err := lock.TryLock(us.ctx)
 	if err != nil {
 		err = errcode.Wrap("刷新Token时设置Redis锁发生错误", err)
 		return nil, err
 	}
 	if !ok {
+		// 同一个RefreshToken正在被其他请求刷新
 		err = errcode.ErrTooManyRequests
 		return nil, err
 	}
+	// 只释放自己加上的锁
+	defer lock.Unlock(us.ctx)
 	tokenSession, err := cache.GetRefreshToken(us.ctx, refreshToken)
 	if err != nil {
 		logger.Error(us.ctx, "GetRefreshTokenCacheErr", "err", err)
@@ -150,6 +147,12 @@ func (us *UserDomainSvc) RefreshToken(refreshToken string) (*do.TokenInfo, error
 		err = errcode.ErrToken
 		return nil, err
 	}
+	// 刷新会让旧的Token失效, 未签名的请求不能刷新APP的Token, 否则被窃取的RefreshToken可以让APP上的登录失效
+	if !app.SignedSessionAllowed(us.ctx, tokenSession.Platform) {
+		logger.Warn(us.ctx, "SESSION_PLATFORM_UNVERIFIED", "userId", tokenSession.UserId, "platform", tokenSession.Platform)
+		err = errcode.ErrForbidden
+		return nil, err
+	}
 	userSession, err := cache.GetUserPlatformSession(us.ctx, tokenSession.UserId, tokenSession.Platform)
 	if err != nil {
 		logger.Error(us.ctx, "GetUserPlatformSessionErr", "err", err)
@@ -288,23 +291,30 @@ func (us *UserDomainSvc) ResetPassword(resetToken, resetCode, newPlainPassword s
 	if userId == 0 || resetCode != code {
 		return errcode.ErrParams
 	}
-	user, err := us.userDao.FindUserById(userId)
-	if err != nil {
-		return errcode.Wrap("ResetPasswordError", err)
-	}
-	// 找不到用户或者用户为封禁状态
-	if user.ID == 0 || user.IsBlocked == enum.UserBlockStateBlocked {
-		return errcode.ErrUserInvalid
-	}
 	newPass, err := util.BcryptPassword(newPlainPassword)
 	if err != nil {
 		return errcode.Wrap("ResetPasswordError", err)
 	}
-	// 更新密码
-	user.Password = newPass
-	err = us.userDao.UpdateUser(user)
+	err = dao.Transaction(us.ctx, func(txCtx context.Context) error {
+		userDao := dao.NewUserDao(txCtx)
+		user, err := userDao.FindUserById(userId)
+		if err != nil {
+			return errcode.Wrap("ResetPasswordError", err)
+		}
+		// 找不到用户或者用户为封禁状态
+		if user.ID == 0 || user.IsBlocked == enum.UserBlockStateBlocked {
+			return errcode.ErrUserInvalid
+		}
+		// 更新密码
+		user.Password = newPass
+		err = userDao.UpdateUser(user)
+		if err != nil {
+			return errcode.Wrap("ResetPasswordError", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return errcode.Wrap("ResetPasswordError", err)
+		return err
 	}
 	// 删掉用户所有已存的Session
 	err = cache.DelUserSessions(us.ctx, userId)
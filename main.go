@@ -1,18 +1,62 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-study-lab/go-mall/api/router"
 	"github.com/go-study-lab/go-mall/common/enum"
+	"github.com/go-study-lab/go-mall/common/i18n"
 	"github.com/go-study-lab/go-mall/config"
+	"github.com/go-study-lab/go-mall/dal/cache"
+	"github.com/go-study-lab/go-mall/dal/dao"
+	"github.com/go-study-lab/go-mall/dal/migration"
 )
 
+// 子命令执行完直接退出, 不启动HTTP服务
+//
+//	go-mall migrate up|down|status|create  数据库迁移
+//	go-mall secret encrypt|decrypt [value]  加解密配置文件中的敏感配置项
+//	go-mall errcode [json|markdown]         导出错误码目录, 提供给客户端团队
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s error: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 
-	if config.App.Env == enum.ModeProd {
+	// 子命令不一定用到Redis和数据库, 启动服务前再连接, 连接不上时阻止服务启动
+	cache.Connect()
+	dao.Connect()
+	if err := migration.AutoMigrate(context.Background()); err != nil {
+		panic(err)
+	}
+	if config.App().Env == enum.ModeProd {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	g := gin.New()
 	router.RegisterRoutes(g)
 	g.Run(":8080")
 }
+
+var commands = map[string]func(args []string) error{
+	"migrate": func(args []string) error {
+		return migration.RunCommand(args, os.Stdout)
+	},
+	"secret": func(args []string) error {
+		return config.RunSecretCommand(args, os.Stdin, os.Stdout)
+	},
+	"errcode": func(args []string) error {
+		format := i18n.CatalogFormatMarkdown
+		if len(args) > 0 {
+			format = args[0]
+		}
+		return i18n.WriteErrorCodeCatalog(os.Stdout, format)
+	},
+}